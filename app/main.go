@@ -1,47 +1,113 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"torrent-client/client"
+	"torrent-client/tracker"
 )
 
+// resumeInterval is how often the progress of a download is saved.
+const resumeInterval = 30 * time.Second
+
 func main() {
 	if len(os.Args) < 2 {
-		log.Fatal("Usage: ./app [torrent file path]")
+		log.Fatal("Usage: ./app [torrent file path]\n       ./app scrape [torrent file path]...")
+	}
+	if os.Args[1] == "scrape" {
+		scrape(os.Args[2:])
+		return
 	}
 
-	file := DecodeFile(os.Args[1])
-	torrent, ok := DecodeInto[Torrent](file)
-	// torrent := Torrent{MetaInfo: info}
-	if !ok {
-		log.Fatal("Invalid torrent info:", file)
+	torrent, err := client.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
 	}
+	if err := torrent.OpenFiles("."); errors.Is(err, client.ErrResumeStale) {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	defer torrent.Storage.Close()
 
-	resp := DiscoverPeers(torrent.Info[0], torrent.Announce)
-	if resp == nil {
-		log.Fatal("No response received")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if torrent.Left() == 0 {
+		return
 	}
-	torrent.Tracker = NewTrackerResponse(resp)
 
-	if len(torrent.Tracker.Peers) == 0 {
-		log.Fatal("No peers received in response")
+	if err := torrent.DiscoverPeers(ctx); err != nil {
+		log.Fatal(err)
 	}
 
-	for _, i := range torrent.Tracker.Peers {
-		info := torrent.Info[0]
-		conn, err := NewPeerConnection(i, info)
+	// Keep announcing until we are done, then tell the trackers we left.
+	announceCtx, stopAnnouncing := context.WithCancel(ctx)
+	announced := make(chan struct{})
+	go func() {
+		defer close(announced)
+		err := torrent.Session.Run(announceCtx, func(resp tracker.Response, err error) {
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if resp.Warning != "" {
+				log.Println("tracker warning:", resp.Warning)
+			}
+			torrent.AddPeers(resp.Peers)
+		})
 		if err != nil {
-			return
+			log.Println(err)
 		}
-		torrent.Peers = append(torrent.Peers, conn)
-		defer conn.Close()
-	}
+	}()
+	defer func() {
+		stopAnnouncing()
+		<-announced
+	}()
 
-	for n, i := range torrent.Peers {
-		go i.DownloadPiece(n)
+	// Peers found by later announces can still join if none is reachable
+	// now.
+	if err := torrent.Connect(); err != nil {
+		log.Println(err)
 	}
+	defer torrent.Close()
 
-	for _, i := range torrent.Peers {
-		<-i.PieceBuffer
+	// Save progress now and then, so that little is lost if we are killed.
+	saveCtx, stopSaving := context.WithCancel(ctx)
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(resumeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-saveCtx.Done():
+				return
+			case <-ticker.C:
+				if err := torrent.SaveResume("."); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+
+	// Stop downloading on interrupt, saving what we have.
+	if err := torrent.Download(ctx); err != nil {
+		log.Println(err)
+	}
+	stopSaving()
+	<-saved
+	if err := torrent.SaveResume("."); err != nil {
+		log.Println(err)
+	}
+	if torrent.Left() == 0 {
+		if err := torrent.Session.Complete(ctx); err != nil {
+			log.Println(err)
+		}
 	}
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"torrent-client/metainfo"
+	"torrent-client/tracker"
+)
+
+// scrape prints what every tracker of the torrent files at paths knows
+// about them, asking each tracker once for all of its torrents.
+func scrape(paths []string) {
+	if len(paths) == 0 {
+		log.Fatal("Usage: ./app scrape [torrent file path]...")
+	}
+
+	var urls []string
+	hashes := make(map[string][][20]byte)
+	for _, path := range paths {
+		info, err := metainfo.Load(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, tier := range info.Trackers() {
+			for _, url := range tier {
+				if _, ok := hashes[url]; !ok {
+					urls = append(urls, url)
+				}
+				hashes[url] = append(hashes[url], info.InfoHash())
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for _, url := range urls {
+		stats, err := tracker.Scrape(ctx, url, hashes[url]...)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for i, s := range stats {
+			fmt.Printf("%x %s seeders=%d completed=%d leechers=%d\n",
+				hashes[url][i], url, s.Seeders, s.Completed, s.Leechers)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package bencode implements encoding and decoding of the bencode format
+// used by BitTorrent metainfo files and tracker responses.
+package bencode
+
+import (
+	"fmt"
+	"os"
+	r "reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EncodeString encodes value as a length-prefixed byte string.
+func EncodeString(value string) []byte {
+	len := strconv.Itoa(len(value))
+	return []byte(len + ":" + value)
+}
+
+// EncodeSigned encodes a signed integer.
+func EncodeSigned[T int64 | int32 | int](value T) []byte {
+	return []byte("i" + fmt.Sprintf("%d", value) + "e")
+}
+
+// EncodeUnsigned encodes an unsigned integer.
+func EncodeUnsigned[T uint8 | uint64](value T) []byte {
+	return []byte("i" + fmt.Sprintf("%d", value) + "e")
+}
+
+// DecodeString decodes a single bencoded value.
+func DecodeString(value []byte) (any, error) {
+	return NewBytesDecoder(value).Parse()
+}
+
+// DecodeFile reads and decodes the bencoded dictionary stored at path.
+func DecodeFile(path string) (map[string]any, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result, err := NewDecoder(file).Parse()
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := result.(map[string]any)
+	if !ok {
+		return nil, &SyntaxError{Offset: 0, Msg: "expected dictionary"}
+	}
+	return dict, nil
+}
+
+// Decode decodes a single bencoded value. Byte strings are returned as
+// string, integers as int, lists as []any and dictionaries as
+// map[string]any.
+func Decode(input []byte) (any, error) {
+	return NewBytesDecoder(input).Parse()
+}
+
+var cases *regexp.Regexp = regexp.MustCompile(`([a-z])([A-Z])|([A-Z])([A-Z][a-z])`)
+
+func titleToWords(s string) string {
+	return strings.ToLower(cases.ReplaceAllString(s, `${1}${3} ${2}${4}`))
+}
+
+// field is an exported struct field as seen by Marshal and Unmarshal.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields lists the fields of t in declaration order, flattening
+// untagged embedded structs. Keys are taken from the `bencoded` tag or,
+// failing that, by splitting the Go name into lower case words
+// (PieceLength becomes "piece length").
+func structFields(t r.Type) []field {
+	var result []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("bencoded")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == r.Struct {
+			for _, inner := range structFields(f.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				result = append(result, inner)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = titleToWords(f.Name)
+		}
+		result = append(result, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return result
+}
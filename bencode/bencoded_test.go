@@ -0,0 +1,538 @@
+package bencode
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStructEncode(t *testing.T) {
+	blabers := struct {
+		A int
+		B string
+	}{1234, "let's go"}
+	result, err := Marshal(blabers)
+
+	if err != nil || !bytes.Equal(result, []byte("d1:ai1234e1:b8:let's goe")) {
+		t.Error(string(result), err)
+	}
+}
+
+func TestBencodedInt(t *testing.T) {
+	cases := []struct {
+		input    []byte
+		expected any
+		strict   any
+	}{
+		{[]byte("i42e"), 42, 42},
+		{[]byte("i-999e"), -999, -999},
+		{[]byte("i0e"), 0, 0},
+		{[]byte("i0000e"), 0, nil},
+		{[]byte("i-0e"), 0, nil},
+		{[]byte("i-042e"), -42, nil},
+		{[]byte("i+42e"), 42, nil},
+		{[]byte("i42ei43e"), 42, nil},
+		{[]byte("ie"), nil, nil},
+		{[]byte("i999999999999999999999999e"), nil, nil},
+		{[]byte("i0.9e"), nil, nil},
+		{[]byte("i42.0e"), nil, nil},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			d := Decoder{input: c.input}
+			result, err := d.Parse()
+
+			if c.expected == nil {
+				if err == nil {
+					t.Error(result)
+				}
+			} else {
+				if c.expected != result {
+					t.Error(err)
+				}
+			}
+
+			strict := Decoder{input: c.input, Strict: true}
+			result, err = strict.Parse()
+
+			if c.strict == nil {
+				if err == nil {
+					t.Error("strict:", result)
+				}
+			} else {
+				if c.strict != result {
+					t.Error("strict:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBencodedString(t *testing.T) {
+	cases := []struct {
+		input    []byte
+		expected any
+		strict   any
+	}{
+		{[]byte("2:la"), "la", "la"},
+		{[]byte("4:blab"), "blab", "blab"},
+		{[]byte("0:"), "", ""},
+		{[]byte("0:asd"), "", nil},
+		{[]byte("2:helloimtoolong"), "he", nil},
+		{[]byte("02:la"), "la", nil},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			d := Decoder{input: c.input}
+			result, err := d.Parse()
+
+			if c.expected == nil {
+				if err == nil {
+					t.Error(result)
+				}
+			} else {
+				if c.expected != result {
+					t.Error(err)
+				}
+			}
+
+			strict := Decoder{input: c.input, Strict: true}
+			result, err = strict.Parse()
+
+			if c.strict == nil {
+				if err == nil {
+					t.Error("strict:", result)
+				}
+			} else {
+				if c.strict != result {
+					t.Error("strict:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBencodedList(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected []any
+	}{
+		{"example", []byte("l5:helloi52ee"), []any{"hello", 52}},
+		{"empty list", []byte("le"), []any{}},
+		{"list of strings", []byte("l1:a1:be"), []any{"a", "b"}},
+		{"list of ints", []byte("li1ei2ei3ee"), []any{1, 2, 3}},
+		{"nested list", []byte("ll5:helloi42eee"), []any{[]any{"hello", 42}}},
+		{"list with list", []byte("l4:spaml1:a1:bee"), []any{"spam", []any{"a", "b"}}},
+		{"list with empty string", []byte("l0:1:ae"), []any{"", "a"}},
+		// {"deeply nested list", "lllee", []any{[]any{[]any{}}}},
+		{"unterminated list", []byte("l5:helloi52e"), nil},
+		{"list with bad integer", []byte("li0.4ee"), nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Decoder{input: c.input}
+			result, err := d.parseList()
+
+			if c.expected == nil {
+				if err == nil {
+					t.Error(result)
+				}
+			} else {
+				if !reflect.DeepEqual(c.expected, result) {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+//go:embed tests/sample.torrent
+var sample_torrent []byte
+
+func TestBencodedDictionary(t *testing.T) {
+	sample := map[string]any{
+		"announce":   "http://bittorrent-test-tracker.codecrafters.io/announce",
+		"created by": "mktorrent 1.1",
+		"info": map[string]any{
+			"name":         "sample.txt",
+			"length":       92063,
+			"piece length": 32768,
+			"pieces":       "\xe8v\xf6z*\x88\x86\xe8\xf3k\x13g&\xc3\x0f\xa2\x97\x03\x02-n\"u\xe6\x04\xa0vfVsn\x81\xff\x10\xb5R\x04\xad\x8d5\xf0\r\x93z\x02\x13\xdf\x19\x82\xbc\x8d\tr'\xad\x9e\x90\x9a\xcc\x17"},
+	}
+	cases := []struct {
+		name     string
+		input    []byte
+		expected map[string]any
+		strict   map[string]any
+	}{
+		{"example", []byte("d3:foo3:bar5:helloi52ee"), map[string]any{"foo": "bar", "hello": 52}, map[string]any{"foo": "bar", "hello": 52}},
+		{"sample.torrent", sample_torrent, sample, sample},
+		{"unsorted keys", []byte("d1:bi1e1:ai2ee"), map[string]any{"a": 2, "b": 1}, nil},
+		{"duplicate keys", []byte("d1:ai1e1:ai2ee"), map[string]any{"a": 2}, nil},
+		{"unsorted nested keys", []byte("d1:ad1:ci1e1:bi2eee"), map[string]any{"a": map[string]any{"b": 2, "c": 1}}, nil},
+		{"trailing data", []byte("d1:ai1eei2e"), map[string]any{"a": 1}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Decoder{input: c.input}
+			result, err := d.parseDict()
+
+			if c.expected == nil {
+				if err == nil {
+					t.Error(result)
+				}
+			} else {
+				if !reflect.DeepEqual(c.expected, result) {
+					t.Error(err)
+				}
+			}
+
+			strict := Decoder{input: c.input, Strict: true}
+			strictResult, err := strict.Parse()
+
+			if c.strict == nil {
+				if err == nil || IsCanonical(c.input) {
+					t.Error("strict:", strictResult)
+				}
+			} else {
+				if !reflect.DeepEqual(c.strict, strictResult) || !IsCanonical(c.input) {
+					t.Error("strict:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  []byte
+		offset int
+	}{
+		{"empty input", []byte(""), 0},
+		{"unknown type", []byte("x"), 0},
+		{"truncated string", []byte("l5:hele"), 1},
+		{"unterminated integer", []byte("li42"), 1},
+		{"unterminated dict", []byte("d3:foo3:bar"), 11},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Decode(c.input)
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrMalformed) {
+				t.Fatal(err)
+			}
+			if syntaxErr.Offset != c.offset {
+				t.Error(syntaxErr)
+			}
+		})
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	input := []byte("d4:infod6:lengthi3e4:name1:ae5:otherl1:aee")
+	d := NewBytesDecoder(input)
+	if _, err := d.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, ok := d.RawValue("info")
+	if !ok || !bytes.Equal(raw, []byte("d6:lengthi3e4:name1:ae")) {
+		t.Error(string(raw))
+	}
+	raw, ok = d.RawValue("other")
+	if !ok || !bytes.Equal(raw, []byte("l1:ae")) {
+		t.Error(string(raw))
+	}
+	if _, ok := d.RawValue("length"); ok {
+		t.Error("nested keys should not be captured")
+	}
+}
+
+type testFile struct {
+	Length int64
+	Path   []string
+	Md5sum string `bencoded:"md5sum,omitempty"`
+}
+
+type testInfo struct {
+	Name        string
+	PieceLength int
+	Pieces      []byte
+	Files       []testFile
+	Private     *bool  `bencoded:",omitempty"`
+	Ignored     string `bencoded:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	private := true
+	cases := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{"int", 42, "i42e"},
+		{"negative", int8(-3), "i-3e"},
+		{"bool", true, "i1e"},
+		{"string", "spam", "4:spam"},
+		{"bytes", []byte{0, 1}, "2:\x00\x01"},
+		{"byte array", [2]byte{'h', 'i'}, "2:hi"},
+		{"list", []any{"a", 1, []int{2}}, "l1:ai1eli2eee"},
+		{"map sorted keys", map[string]int{"b": 2, "a": 1, "aa": 3}, "d1:ai1e2:aai3e1:bi2ee"},
+		{"struct", testInfo{
+			Name:        "x",
+			PieceLength: 2,
+			Pieces:      []byte("ab"),
+			Files:       []testFile{{Length: 1, Path: []string{"d", "f"}}},
+			Ignored:     "nope",
+		}, "d5:filesld6:lengthi1e4:pathl1:d1:feee4:name1:x12:piece lengthi2e6:pieces2:abe"},
+		{"pointer", &testFile{Length: 1, Md5sum: "m"}, "d6:lengthi1e6:md5sum1:m4:pathlee"},
+		{"omitempty pointer set", testInfo{Private: &private}, "d5:filesle4:name0:12:piece lengthi0e6:pieces0:7:privatei1ee"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := Marshal(c.input)
+
+			if err != nil || string(result) != c.expected {
+				t.Error(string(result), err)
+			}
+		})
+	}
+
+	if _, err := Marshal(1.5); err == nil {
+		t.Error("floats have no bencoded representation")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var info testInfo
+	input := []byte("d5:filesld6:lengthi1e6:md5sum1:m4:pathl1:d1:feee4:name1:x12:piece lengthi2e6:pieces2:ab7:privatei1e7:unknownd1:ai1eee")
+	if err := Unmarshal(input, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testInfo{
+		Name:        "x",
+		PieceLength: 2,
+		Pieces:      []byte("ab"),
+		Files:       []testFile{{Length: 1, Path: []string{"d", "f"}, Md5sum: "m"}},
+	}
+	if info.Private == nil || !*info.Private {
+		t.Error(info.Private)
+	}
+	info.Private = nil
+	if !reflect.DeepEqual(expected, info) {
+		t.Error(info)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal([]byte("d1:ai1e1:bl1:cee"), &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[string]any{"a": 1, "b": []any{"c"}}, generic) {
+		t.Error(generic)
+	}
+
+	var typeErr *UnmarshalTypeError
+	var file testFile
+	err := Unmarshal([]byte("d6:length3:one4:pathl1:aee"), &file)
+	if !errors.As(err, &typeErr) || typeErr.Field != "length" {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(file.Path, []string{"a"}) {
+		t.Error("decoding should continue past type errors", file)
+	}
+
+	if err := Unmarshal([]byte("i1e"), file); err == nil {
+		t.Error("non-pointer argument must be rejected")
+	}
+}
+
+func TestStreamingDecoder(t *testing.T) {
+	expected, err := NewBytesDecoder(sample_torrent).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(sample_torrent)))
+	result, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Error(result)
+	}
+	if d.InputOffset() != len(sample_torrent) {
+		t.Error(d.InputOffset())
+	}
+
+	raw, ok := d.RawValue("info")
+	if !ok || !bytes.Contains(sample_torrent, raw) || raw[0] != 'd' {
+		t.Error(string(raw))
+	}
+
+	if _, err := d.Parse(); err != io.EOF {
+		t.Error(err)
+	}
+}
+
+func TestStreamingValues(t *testing.T) {
+	d := NewDecoder(strings.NewReader("i1e3:fooli2ee"))
+
+	var values []any
+	for {
+		value, err := d.Parse()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+
+	if !reflect.DeepEqual([]any{1, "foo", []any{2}}, values) {
+		t.Error(values)
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		setup  func(d *Decoder)
+		offset int
+	}{
+		{"depth", "lllleeee", func(d *Decoder) { d.MaxDepth = 3 }, 3},
+		{"string length", "l5:hello2:hie", func(d *Decoder) { d.MaxStringLength = 4 }, 1},
+		{"size", "l5:hello2:hie", func(d *Decoder) { d.MaxSize = 8 }, 8},
+		{"huge string", "9999999999:", func(d *Decoder) {}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(c.input))
+			c.setup(d)
+			_, err := d.Parse()
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimitExceeded) {
+				t.Fatal(err)
+			}
+			if limitErr.Offset != c.offset {
+				t.Error(limitErr)
+			}
+		})
+	}
+
+	t.Run("lying length", func(t *testing.T) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewDecoder(strings.NewReader("67108864:abc")).Parse()
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Error("truncated string accepted")
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Error("allocated", allocated, "bytes for a 12-byte input")
+		}
+	})
+}
+
+func TestToken(t *testing.T) {
+	d := NewDecoder(strings.NewReader("d1:ali1ei2ee1:bi3ee"))
+
+	var tokens []any
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	expected := []any{Delim('d'), "a", Delim('l'), 1, 2, Delim('e'), "b", 3, Delim('e')}
+	if !reflect.DeepEqual(expected, tokens) {
+		t.Error(tokens)
+	}
+}
+
+// testPeers is a compact list of 2-byte ports that controls its encoding.
+type testPeers []uint16
+
+func (p testPeers) MarshalBencode() ([]byte, error) {
+	compact := make([]byte, 0, 2*len(p))
+	for _, port := range p {
+		compact = append(compact, byte(port>>8), byte(port))
+	}
+	return Marshal(compact)
+}
+
+func (p *testPeers) UnmarshalBencode(data []byte) error {
+	var compact []byte
+	if err := Unmarshal(data, &compact); err != nil {
+		return err
+	}
+	for i := 0; i+2 <= len(compact); i += 2 {
+		*p = append(*p, uint16(compact[i])<<8|uint16(compact[i+1]))
+	}
+	return nil
+}
+
+type testBadMarshaler struct{}
+
+func (testBadMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("i1ei2e"), nil
+}
+
+func TestRawMessage(t *testing.T) {
+	var message struct {
+		Info  RawMessage
+		Peers testPeers
+		Other *RawMessage `bencoded:",omitempty"`
+	}
+	input := []byte("d4:infod1:ai1e1:bl1:xee5:peers4:\x1a\xe1\x1a\xe2e")
+	if err := Unmarshal(input, &message); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(message.Info) != "d1:ai1e1:bl1:xee" {
+		t.Error(string(message.Info))
+	}
+	if !reflect.DeepEqual(testPeers{6881, 6882}, message.Peers) {
+		t.Error(message.Peers)
+	}
+
+	result, err := Marshal(message)
+	if err != nil || !bytes.Equal(input, result) {
+		t.Error(string(result), err)
+	}
+
+	var marshalerErr *MarshalerError
+	if _, err := Marshal(testBadMarshaler{}); !errors.As(err, &marshalerErr) {
+		t.Error(err)
+	}
+	if _, err := Marshal(struct{ Info RawMessage }{}); !errors.As(err, &marshalerErr) {
+		t.Error(err)
+	}
+
+	var unsupported *UnsupportedTypeError
+	if _, err := Marshal(struct{ M Marshaler }{}); !errors.As(err, &unsupported) {
+		t.Error("nil Marshaler interface:", err)
+	}
+}
@@ -0,0 +1,237 @@
+package bencode
+
+import (
+	r "reflect"
+)
+
+// Unmarshal decodes the bencoded data into the value pointed to by v.
+//
+// Dictionaries decode into structs, matching keys against each exported
+// field's `bencoded` tag or, failing that, its name split into lower case
+// words. Unknown keys are skipped. Dictionaries also decode into maps with
+// string keys, lists into slices and arrays, byte strings into strings,
+// byte slices and byte arrays, and integers into any integer type or bool.
+// Decoding into an empty interface produces the same values as Decode, and
+// values implementing Unmarshaler are handed their raw encoding.
+//
+// If a value cannot be stored in its destination, Unmarshal skips it,
+// carries on with the rest of the input and returns the first
+// *UnmarshalTypeError it found.
+func Unmarshal(data []byte, v any) error {
+	return NewBytesDecoder(data).Decode(v)
+}
+
+// Decode decodes the next value in the input into the value pointed to by
+// v, following the rules of Unmarshal. When reading from an io.Reader that
+// ends cleanly before the value starts, it returns io.EOF.
+func (d *Decoder) Decode(v any) error {
+	rv := r.ValueOf(v)
+	if rv.Kind() != r.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: r.TypeOf(v)}
+	}
+
+	if err := d.more(); err != nil {
+		return err
+	}
+
+	var typeErr error
+	if err := d.decodeValue(rv, "", &typeErr); err != nil {
+		return err
+	}
+	if err := d.end(); err != nil {
+		return err
+	}
+	return typeErr
+}
+
+// decodeValue decodes the value at the cursor into v. Syntax errors are
+// returned; type mismatches are recorded in typeErr and the value skipped.
+func (d *Decoder) decodeValue(v r.Value, key string, typeErr *error) error {
+	u, v := indirect(v)
+	if u != nil {
+		start := d.InputOffset()
+		d.pin(start)
+		defer d.unpin()
+		if _, err := d.Parse(); err != nil {
+			return err
+		}
+		return u.UnmarshalBencode(d.since(start))
+	}
+
+	if v.Kind() == r.Interface && v.NumMethod() == 0 {
+		val, err := d.Parse()
+		if err != nil {
+			return err
+		}
+		v.Set(r.ValueOf(val))
+		return nil
+	}
+
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	switch c {
+	case 'i':
+		return d.decodeInt(v, key, typeErr)
+	case 'l':
+		return d.decodeList(v, key, typeErr)
+	case 'd':
+		return d.decodeDict(v, key, typeErr)
+	default:
+		return d.decodeStr(v, key, typeErr)
+	}
+}
+
+// mismatch records a type error for the value at the cursor and skips it.
+func (d *Decoder) mismatch(value string, v r.Value, key string, typeErr *error) error {
+	setTypeError(typeErr, value, v, key, d.InputOffset())
+	_, err := d.Parse()
+	return err
+}
+
+func setTypeError(typeErr *error, value string, v r.Value, key string, offset int) {
+	if *typeErr == nil {
+		*typeErr = &UnmarshalTypeError{Value: value, Type: v.Type(), Offset: offset, Field: key}
+	}
+}
+
+func (d *Decoder) decodeInt(v r.Value, key string, typeErr *error) error {
+	start := d.InputOffset()
+	switch v.Kind() {
+	case r.Bool:
+		n, err := d.parseInt()
+		if err != nil {
+			return err
+		}
+		v.SetBool(n != 0)
+	case r.Int, r.Int8, r.Int16, r.Int32, r.Int64:
+		n, err := d.parseInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(int64(n)) {
+			setTypeError(typeErr, "integer", v, key, start)
+			return nil
+		}
+		v.SetInt(int64(n))
+	case r.Uint, r.Uint8, r.Uint16, r.Uint32, r.Uint64, r.Uintptr:
+		n, err := d.parseInt()
+		if err != nil {
+			return err
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			setTypeError(typeErr, "integer", v, key, start)
+			return nil
+		}
+		v.SetUint(uint64(n))
+	default:
+		return d.mismatch("integer", v, key, typeErr)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeStr(v r.Value, key string, typeErr *error) error {
+	switch {
+	case v.Kind() == r.String:
+		s, err := d.parseStr()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case v.Kind() == r.Slice && v.Type().Elem().Kind() == r.Uint8:
+		s, err := d.parseStr()
+		if err != nil {
+			return err
+		}
+		v.SetBytes([]byte(s))
+	case v.Kind() == r.Array && v.Type().Elem().Kind() == r.Uint8:
+		start := d.InputOffset()
+		s, err := d.parseStr()
+		if err != nil {
+			return err
+		}
+		if len(s) != v.Len() {
+			setTypeError(typeErr, "string", v, key, start)
+			return nil
+		}
+		r.Copy(v, r.ValueOf([]byte(s)))
+	default:
+		return d.mismatch("string", v, key, typeErr)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeList(v r.Value, key string, typeErr *error) error {
+	switch v.Kind() {
+	case r.Slice:
+		v.SetLen(0)
+		elem := v.Type().Elem()
+		return d.walkList(func() error {
+			item := r.New(elem).Elem()
+			if err := d.decodeValue(item, key, typeErr); err != nil {
+				return err
+			}
+			v.Set(r.Append(v, item))
+			return nil
+		})
+	case r.Array:
+		i := 0
+		err := d.walkList(func() error {
+			if i >= v.Len() {
+				_, err := d.Parse()
+				return err
+			}
+			err := d.decodeValue(v.Index(i), key, typeErr)
+			i++
+			return err
+		})
+		for ; i < v.Len(); i++ {
+			v.Index(i).SetZero()
+		}
+		return err
+	default:
+		return d.mismatch("list", v, key, typeErr)
+	}
+}
+
+func (d *Decoder) decodeDict(v r.Value, key string, typeErr *error) error {
+	switch v.Kind() {
+	case r.Struct:
+		fields := make(map[string][]int)
+		for _, f := range structFields(v.Type()) {
+			if _, ok := fields[f.name]; !ok {
+				fields[f.name] = f.index
+			}
+		}
+
+		return d.walkDict(func(key string) error {
+			index, ok := fields[key]
+			if !ok {
+				_, err := d.Parse()
+				return err
+			}
+			return d.decodeValue(v.FieldByIndex(index), key, typeErr)
+		})
+	case r.Map:
+		if v.Type().Key().Kind() != r.String {
+			return d.mismatch("dictionary", v, key, typeErr)
+		}
+		if v.IsNil() {
+			v.Set(r.MakeMap(v.Type()))
+		}
+
+		elem := v.Type().Elem()
+		return d.walkDict(func(key string) error {
+			item := r.New(elem).Elem()
+			if err := d.decodeValue(item, key, typeErr); err != nil {
+				return err
+			}
+			v.SetMapIndex(r.ValueOf(key).Convert(v.Type().Key()), item)
+			return nil
+		})
+	default:
+		return d.mismatch("dictionary", v, key, typeErr)
+	}
+}
@@ -0,0 +1,503 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// DefaultMaxDepth is the nesting limit applied by NewDecoder and
+	// NewBytesDecoder.
+	DefaultMaxDepth = 128
+	// DefaultMaxStringLength is the string length limit applied by
+	// NewDecoder and NewBytesDecoder.
+	DefaultMaxStringLength = 64 << 20
+
+	readChunk = 4096
+)
+
+// Decoder parses bencoded values, either from an in-memory buffer or
+// incrementally from an io.Reader.
+//
+// The limits below are checked as the input is consumed; a value of zero
+// disables the corresponding check.
+type Decoder struct {
+	// MaxDepth limits how deeply lists and dictionaries may nest.
+	MaxDepth int
+	// MaxStringLength limits the length of a single byte string.
+	MaxStringLength int
+	// MaxSize limits the total number of bytes consumed.
+	MaxSize int
+
+	// Strict rejects input that is not in canonical form: integers and
+	// string lengths with leading zeros, negative zero, dictionary keys
+	// that are unsorted or repeated, and data following the top-level
+	// value. Token checks the form of integers and strings only.
+	Strict bool
+
+	r     io.Reader
+	input []byte // window of the input, starting at offset base
+	base  int
+	cur   int
+	depth int
+	raw   map[string][]byte
+
+	// pins counts values whose raw bytes are being captured, the first of
+	// which starts at pinAt. Pinned bytes are never discarded.
+	pins  int
+	pinAt int
+}
+
+// NewDecoder returns a Decoder reading from r. Only as much of r is read as
+// is needed to decode the values asked for.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		MaxDepth:        DefaultMaxDepth,
+		MaxStringLength: DefaultMaxStringLength,
+		r:               r,
+	}
+}
+
+// NewBytesDecoder returns a Decoder reading from input.
+func NewBytesDecoder(input []byte) *Decoder {
+	return &Decoder{
+		MaxDepth:        DefaultMaxDepth,
+		MaxStringLength: DefaultMaxStringLength,
+		input:           input,
+	}
+}
+
+// RawValue returns the bytes of the value stored under key in the outermost
+// dictionary parsed so far, exactly as they appeared in the input.
+func (d *Decoder) RawValue(key string) ([]byte, bool) {
+	raw, ok := d.raw[key]
+	return raw, ok
+}
+
+// InputOffset returns the number of bytes consumed so far.
+func (d *Decoder) InputOffset() int {
+	return d.base + d.cur
+}
+
+func (d *Decoder) syntaxError(offset int, format string, args ...any) error {
+	return &SyntaxError{Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *Decoder) limitError(offset int, limit string, max int) error {
+	return &LimitError{Offset: offset, Limit: limit, Max: max}
+}
+
+// fill makes at least n bytes past the cursor available in the window,
+// reading more input if needed. It returns io.ErrUnexpectedEOF if the
+// input ends first.
+func (d *Decoder) fill(n int) error {
+	if d.MaxSize > 0 && d.InputOffset()+n > d.MaxSize {
+		return d.limitError(d.InputOffset(), "size", d.MaxSize)
+	}
+
+	for len(d.input)-d.cur < n {
+		if d.r == nil {
+			return io.ErrUnexpectedEOF
+		}
+		d.compact()
+
+		// Grow the window as data arrives, at most doubling it each
+		// round, rather than trusting the length the input declares.
+		if len(d.input) == cap(d.input) {
+			want := len(d.input) + max(n-(len(d.input)-d.cur), readChunk)
+			grown := make([]byte, len(d.input), min(want, max(2*cap(d.input), len(d.input)+readChunk)))
+			copy(grown, d.input)
+			d.input = grown
+		}
+
+		m, err := d.r.Read(d.input[len(d.input):cap(d.input)])
+		d.input = d.input[:len(d.input)+m]
+		if err == io.EOF {
+			if m == 0 {
+				return io.ErrUnexpectedEOF
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact discards the consumed part of the window that is not pinned.
+func (d *Decoder) compact() {
+	keep := d.cur
+	if d.pins > 0 {
+		keep = min(keep, d.pinAt-d.base)
+	}
+	if keep == 0 {
+		return
+	}
+
+	n := copy(d.input, d.input[keep:])
+	d.input = d.input[:n]
+	d.base += keep
+	d.cur -= keep
+}
+
+func (d *Decoder) pin(offset int) {
+	if d.pins == 0 {
+		d.pinAt = offset
+	}
+	d.pins += 1
+}
+
+func (d *Decoder) unpin() {
+	d.pins -= 1
+}
+
+// since returns the input consumed since offset, which must be pinned.
+func (d *Decoder) since(offset int) []byte {
+	raw := d.input[offset-d.base : d.cur]
+	if d.r != nil {
+		// The window is reused once unpinned.
+		raw = bytes.Clone(raw)
+	}
+	return raw
+}
+
+// eof converts a short input from fill into a syntax error at offset.
+func (d *Decoder) eof(err error, offset int, format string, args ...any) error {
+	if err == io.ErrUnexpectedEOF {
+		return d.syntaxError(offset, format, args...)
+	}
+	return err
+}
+
+// peek returns the byte at the cursor without consuming it.
+func (d *Decoder) peek() (byte, error) {
+	if err := d.fill(1); err != nil {
+		return 0, d.eof(err, d.InputOffset(), "unexpected end of input")
+	}
+	return d.input[d.cur], nil
+}
+
+// more reports io.EOF when a reader is exhausted between two values.
+func (d *Decoder) more() error {
+	if d.depth == 0 && d.r != nil {
+		if err := d.fill(1); err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+	}
+	return nil
+}
+
+// Parse decodes the next value in the input. When reading from an
+// io.Reader that ends cleanly before the value starts, it returns io.EOF.
+func (d *Decoder) Parse() (result any, err error) {
+	if d.depth > 0 {
+		return d.parse()
+	}
+
+	if err := d.more(); err != nil {
+		return nil, err
+	}
+	if result, err = d.parse(); err != nil {
+		return nil, err
+	}
+	return result, d.end()
+}
+
+// end checks, in strict mode, that nothing follows a top-level value.
+func (d *Decoder) end() error {
+	if !d.Strict {
+		return nil
+	}
+	if err := d.fill(1); err == nil {
+		return d.syntaxError(d.InputOffset(), "trailing data after value")
+	} else if err != io.ErrUnexpectedEOF {
+		return err
+	}
+	return nil
+}
+
+func (d *Decoder) parse() (result any, err error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return d.parseStr()
+	case 'i':
+		return d.parseInt()
+	case 'd':
+		return d.parseDict()
+	case 'l':
+		return d.parseList()
+	default:
+		return nil, d.syntaxError(d.InputOffset(), "unidentified value %q", c)
+	}
+}
+
+// Delim is a list or dictionary delimiter returned by Token: 'l' or 'd'
+// when one starts and 'e' when it ends.
+type Delim byte
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Token returns the next token in the input: a Delim, a string for byte
+// strings or an int for integers. Dictionary keys are returned as strings
+// alternating with their values. At the end of an io.Reader's input, Token
+// returns io.EOF.
+func (d *Decoder) Token() (any, error) {
+	if err := d.more(); err != nil {
+		return nil, err
+	}
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return d.parseStr()
+	case 'i':
+		return d.parseInt()
+	case 'd', 'l':
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		return Delim(c), nil
+	case 'e':
+		if d.depth == 0 {
+			return nil, d.syntaxError(d.InputOffset(), "unexpected end of container")
+		}
+		d.cur += 1
+		d.depth -= 1
+		return Delim(c), nil
+	default:
+		return nil, d.syntaxError(d.InputOffset(), "unidentified value %q", c)
+	}
+}
+
+// enter consumes the opening delimiter of a list or dictionary.
+func (d *Decoder) enter() error {
+	if d.MaxDepth > 0 && d.depth >= d.MaxDepth {
+		return d.limitError(d.InputOffset(), "depth", d.MaxDepth)
+	}
+	d.cur += 1
+	d.depth += 1
+	return nil
+}
+
+func (d *Decoder) parseDict() (result map[string]any, err error) {
+	result = make(map[string]any)
+	err = d.walkDict(func(key string) error {
+		val, e := d.Parse()
+		if e != nil {
+			return e
+		}
+
+		result[key] = val
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// walkDict consumes the dictionary at the cursor, calling fn with the
+// cursor on each value. fn must consume exactly that value.
+func (d *Decoder) walkDict(fn func(key string) error) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth -= 1 }()
+	if d.depth == 1 {
+		d.raw = make(map[string][]byte)
+	}
+
+	var prev string
+	for i := 0; ; i++ {
+		c, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if c == 'e' {
+			break
+		}
+
+		keyStart := d.InputOffset()
+		key, keyErr := d.parseStr()
+		if keyErr != nil {
+			return keyErr
+		}
+		if d.Strict && i > 0 {
+			if key == prev {
+				return d.syntaxError(keyStart, "duplicate dictionary key %q", key)
+			} else if key < prev {
+				return d.syntaxError(keyStart, "dictionary key %q out of order", key)
+			}
+		}
+		prev = key
+
+		start := d.InputOffset()
+		if d.depth == 1 {
+			d.pin(start)
+		}
+		err = fn(key)
+		if d.depth == 1 {
+			if err == nil {
+				d.raw[key] = d.since(start)
+			}
+			d.unpin()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	d.cur += 1
+	return nil
+}
+
+func (d *Decoder) parseStr() (result string, err error) {
+	start := d.InputOffset()
+
+	// Scan the length prefix up to the colon.
+	n := 0
+	for {
+		if err := d.fill(n + 1); err != nil {
+			return "", d.eof(err, start, "unterminated string length")
+		}
+		if d.input[d.cur+n] == ':' {
+			break
+		}
+		if n > 20 {
+			return "", d.syntaxError(start, "invalid string length %q", d.input[d.cur:d.cur+n])
+		}
+		n++
+	}
+
+	lengthStr := d.input[d.cur : d.cur+n]
+
+	length, e := strconv.Atoi(string(lengthStr))
+	if e != nil || length < 0 {
+		return "", d.syntaxError(start, "invalid string length %q", lengthStr)
+	}
+	if d.Strict && !canonicalInt(lengthStr) {
+		return "", d.syntaxError(start, "non-canonical string length %q", lengthStr)
+	}
+	if d.MaxStringLength > 0 && length > d.MaxStringLength {
+		return "", d.limitError(start, "string length", d.MaxStringLength)
+	}
+	if length > math.MaxInt32 {
+		return "", d.syntaxError(start, "invalid string length %q", lengthStr)
+	}
+	if err := d.fill(n + 1 + length); err != nil {
+		return "", d.eof(err, start, "string of length %d exceeds input", length)
+	}
+
+	result, err = string(d.input[d.cur+n+1:d.cur+n+1+length]), nil
+	d.cur += n + 1 + length
+	return
+}
+
+func (d *Decoder) parseInt() (result int, err error) {
+	start := d.InputOffset()
+
+	// Scan the digits up to the terminating e.
+	n := 1
+	for {
+		if err := d.fill(n + 1); err != nil {
+			return 0, d.eof(err, start, "unterminated integer")
+		}
+		if d.input[d.cur+n] == 'e' {
+			break
+		}
+		if n > 21 {
+			return 0, d.syntaxError(start, "invalid integer %q", d.input[d.cur+1:d.cur+n])
+		}
+		n++
+	}
+
+	digits := d.input[d.cur+1 : d.cur+n]
+	result, e := strconv.Atoi(string(digits))
+	if e != nil {
+		return 0, d.syntaxError(start, "invalid integer %q", digits)
+	}
+	if d.Strict && !canonicalInt(digits) {
+		return 0, d.syntaxError(start, "non-canonical integer %q", digits)
+	}
+	d.cur += n + 1
+	return
+}
+
+func (d *Decoder) parseList() (result []any, err error) {
+	result = []any{}
+	err = d.walkList(func() error {
+		if element, err := d.Parse(); err != nil {
+			return err
+		} else {
+			result = append(result, element)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// walkList consumes the list at the cursor, calling fn with the cursor on
+// each element. fn must consume exactly that element.
+func (d *Decoder) walkList(fn func() error) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth -= 1 }()
+
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if c == 'e' {
+			break
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	d.cur += 1
+	return nil
+}
+
+// canonicalInt reports whether digits is the shortest decimal form of the
+// number it holds: no sign other than a leading minus, no leading zeros and
+// no negative zero.
+func canonicalInt(digits []byte) bool {
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+		if len(digits) > 0 && digits[0] == '0' {
+			return false
+		}
+	}
+	if len(digits) == 0 || digits[0] == '+' {
+		return false
+	}
+	return digits[0] != '0' || len(digits) == 1
+}
+
+// IsCanonical reports whether data holds exactly one value in canonical
+// bencoding, as checked by a Decoder in strict mode.
+func IsCanonical(data []byte) bool {
+	d := NewBytesDecoder(data)
+	d.Strict = true
+	_, err := d.Parse()
+	return err == nil
+}
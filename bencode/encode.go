@@ -0,0 +1,160 @@
+package bencode
+
+import (
+	"bytes"
+	r "reflect"
+	"slices"
+)
+
+// Marshal returns the canonical bencoding of v.
+//
+// Integers and bools encode as integers, strings, byte slices and byte
+// arrays as byte strings, other slices and arrays as lists, and structs and
+// maps with string keys as dictionaries with their keys sorted. Struct keys
+// follow the same rules as Unmarshal; fields tagged "omitempty" are left
+// out when they hold their zero value. Pointers and interfaces encode as the
+// value they point to. Values implementing Marshaler encode themselves.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, r.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, v r.Value) error {
+	if !v.IsValid() {
+		return &UnsupportedTypeError{Type: r.TypeFor[any]()}
+	}
+	if m, ok := marshaler(v); ok {
+		return encodeMarshaler(buf, v.Type(), m)
+	}
+
+	switch v.Kind() {
+	case r.Bool:
+		if v.Bool() {
+			buf.WriteString("i1e")
+		} else {
+			buf.WriteString("i0e")
+		}
+	case r.Int, r.Int8, r.Int16, r.Int32, r.Int64:
+		buf.Write(EncodeSigned(v.Int()))
+	case r.Uint, r.Uint8, r.Uint16, r.Uint32, r.Uint64, r.Uintptr:
+		buf.Write(EncodeUnsigned(v.Uint()))
+	case r.String:
+		buf.Write(EncodeString(v.String()))
+	case r.Slice:
+		if v.Type().Elem().Kind() == r.Uint8 {
+			buf.Write(EncodeString(string(v.Bytes())))
+			return nil
+		}
+		return encodeList(buf, v)
+	case r.Array:
+		if v.Type().Elem().Kind() == r.Uint8 {
+			b := make([]byte, v.Len())
+			r.Copy(r.ValueOf(b), v)
+			buf.Write(EncodeString(string(b)))
+			return nil
+		}
+		return encodeList(buf, v)
+	case r.Map:
+		return encodeMap(buf, v)
+	case r.Struct:
+		return encodeStruct(buf, v)
+	case r.Pointer, r.Interface:
+		if v.IsNil() {
+			return &UnsupportedTypeError{Type: v.Type()}
+		}
+		return encodeValue(buf, v.Elem())
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+	return nil
+}
+
+func encodeMarshaler(buf *bytes.Buffer, t r.Type, m Marshaler) error {
+	data, err := m.MarshalBencode()
+	if err != nil {
+		return &MarshalerError{Type: t, Err: err}
+	}
+
+	// Check the output is exactly one value before splicing it in.
+	d := NewBytesDecoder(data)
+	if _, err := d.Parse(); err != nil {
+		return &MarshalerError{Type: t, Err: err}
+	}
+	if d.InputOffset() != len(data) {
+		return &MarshalerError{Type: t, Err: d.syntaxError(d.InputOffset(), "trailing data after value")}
+	}
+
+	buf.Write(data)
+	return nil
+}
+
+func encodeList(buf *bytes.Buffer, v r.Value) error {
+	buf.WriteByte('l')
+	for i := range v.Len() {
+		if err := encodeValue(buf, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('e')
+	return nil
+}
+
+func encodeMap(buf *bytes.Buffer, v r.Value) error {
+	if v.Type().Key().Kind() != r.String {
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b r.Value) int {
+		return bytes.Compare([]byte(a.String()), []byte(b.String()))
+	})
+
+	buf.WriteByte('d')
+	for _, key := range keys {
+		buf.Write(EncodeString(key.String()))
+		if err := encodeValue(buf, v.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('e')
+	return nil
+}
+
+func encodeStruct(buf *bytes.Buffer, v r.Value) error {
+	fields := structFields(v.Type())
+	slices.SortStableFunc(fields, func(a, b field) int {
+		return bytes.Compare([]byte(a.name), []byte(b.name))
+	})
+
+	buf.WriteByte('d')
+	for i, f := range fields {
+		if i > 0 && fields[i-1].name == f.name {
+			continue
+		}
+		value := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(value) {
+			continue
+		}
+
+		buf.Write(EncodeString(f.name))
+		if err := encodeValue(buf, value); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('e')
+	return nil
+}
+
+func isEmptyValue(v r.Value) bool {
+	switch v.Kind() {
+	case r.Array, r.Map, r.Slice, r.String:
+		return v.Len() == 0
+	case r.Pointer, r.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
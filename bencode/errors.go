@@ -0,0 +1,102 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	r "reflect"
+)
+
+// ErrMalformed is matched by every error caused by invalid bencoded input.
+var ErrMalformed = errors.New("bencode: malformed input")
+
+// SyntaxError describes malformed input and where it was found.
+type SyntaxError struct {
+	Offset int // byte offset at which the error was detected
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: %s at offset %d", e.Msg, e.Offset)
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrMalformed
+}
+
+// ErrLimitExceeded is matched by every error caused by input exceeding one
+// of the Decoder's limits.
+var ErrLimitExceeded = errors.New("bencode: limit exceeded")
+
+// LimitError describes input that exceeds one of the Decoder's limits.
+type LimitError struct {
+	Offset int
+	Limit  string // "depth", "string length" or "size"
+	Max    int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("bencode: %s exceeds limit of %d at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// UnsupportedTypeError is returned by Marshal when asked to encode a value
+// that has no bencoded representation.
+type UnsupportedTypeError struct {
+	Type r.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "bencode: unsupported type: " + e.Type.String()
+}
+
+// InvalidUnmarshalError is returned by Unmarshal when its argument is not a
+// non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type r.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "bencode: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != r.Pointer {
+		return "bencode: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "bencode: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// UnmarshalTypeError describes a bencoded value that cannot be stored in
+// the Go value it was decoded into.
+type UnmarshalTypeError struct {
+	Value  string // "integer", "string", "list" or "dictionary"
+	Type   r.Type
+	Offset int
+	Field  string // dictionary key holding the value, if any
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("bencode: cannot decode %s into field %q of type %s at offset %d",
+			e.Value, e.Field, e.Type, e.Offset)
+	}
+	return fmt.Sprintf("bencode: cannot decode %s into Go value of type %s at offset %d",
+		e.Value, e.Type, e.Offset)
+}
+
+// MarshalerError wraps an error returned by, or invalid output produced by,
+// a type's MarshalBencode method.
+type MarshalerError struct {
+	Type r.Type
+	Err  error
+}
+
+func (e *MarshalerError) Error() string {
+	return "bencode: error calling MarshalBencode for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,80 @@
+package bencode
+
+import (
+	"errors"
+	r "reflect"
+)
+
+// Marshaler is implemented by types that encode themselves. The output must
+// be a single well-formed bencoded value.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves. The input is
+// the raw encoding of a single value; implementations that keep it must
+// copy it.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+// RawMessage is a raw encoded bencoded value. It can be used to delay
+// decoding part of a message, or to embed a precomputed encoding.
+type RawMessage []byte
+
+// MarshalBencode returns m as the bencoding of m.
+func (m RawMessage) MarshalBencode() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, errors.New("bencode: empty RawMessage")
+	}
+	return m, nil
+}
+
+// UnmarshalBencode sets *m to a copy of data.
+func (m *RawMessage) UnmarshalBencode(data []byte) error {
+	if m == nil {
+		return errors.New("bencode: UnmarshalBencode on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+var (
+	marshalerType   = r.TypeFor[Marshaler]()
+	unmarshalerType = r.TypeFor[Unmarshaler]()
+)
+
+// marshaler returns the Marshaler implemented by v or its address.
+func marshaler(v r.Value) (Marshaler, bool) {
+	if v.Type().Implements(marshalerType) {
+		// Nil pointers and interfaces are reported by encodeValue.
+		if (v.Kind() == r.Pointer || v.Kind() == r.Interface) && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(Marshaler), true
+	}
+	if v.Kind() != r.Pointer && v.CanAddr() && v.Addr().Type().Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler), true
+	}
+	return nil, false
+}
+
+// indirect walks down v, allocating nil pointers, until it finds an
+// Unmarshaler or a non-pointer value.
+func indirect(v r.Value) (Unmarshaler, r.Value) {
+	for {
+		if v.Kind() != r.Pointer && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+			return v.Addr().Interface().(Unmarshaler), v
+		}
+		if v.Kind() != r.Pointer {
+			return nil, v
+		}
+		if v.IsNil() {
+			v.Set(r.New(v.Type().Elem()))
+		}
+		if v.Type().Implements(unmarshalerType) {
+			return v.Interface().(Unmarshaler), v
+		}
+		v = v.Elem()
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"net"
+	"net/netip"
+)
+
+// interleave orders peers for dialing, alternating between IPv6 and IPv4
+// addresses, IPv6 first, so that neither family can starve the other when
+// one of them is broken on this host.
+func interleave(peers []netip.AddrPort) []netip.AddrPort {
+	var v6, v4 []netip.AddrPort
+	for _, i := range peers {
+		if i.Addr().Unmap().Is6() {
+			v6 = append(v6, i)
+		} else {
+			v4 = append(v4, i)
+		}
+	}
+
+	result := make([]netip.AddrPort, 0, len(peers))
+	for len(v6) > 0 || len(v4) > 0 {
+		if len(v6) > 0 {
+			result = append(result, v6[0])
+			v6 = v6[1:]
+		}
+		if len(v4) > 0 {
+			result = append(result, v4[0])
+			v4 = v4[1:]
+		}
+	}
+	return result
+}
+
+// localIPv6 returns the global IPv6 address this host would use to reach
+// the internet, or the zero Addr if it has none. No packets are sent.
+func localIPv6() netip.Addr {
+	conn, err := net.Dial("udp6", "[2001:db8::1]:6881")
+	if err != nil {
+		return netip.Addr{}
+	}
+	defer conn.Close()
+
+	addr := conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return netip.Addr{}
+	}
+	return addr
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestInterleave(t *testing.T) {
+	var peers []netip.AddrPort
+	for _, i := range []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1", "[::ffff:10.0.0.4]:1", "[2001:db8::1]:1"} {
+		peers = append(peers, netip.MustParseAddrPort(i))
+	}
+
+	result := interleave(peers)
+	expected := []netip.AddrPort{peers[4], peers[0], peers[1], peers[2], peers[3]}
+	if !reflect.DeepEqual(expected, result) {
+		t.Error(result)
+	}
+}
@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"torrent-client/metainfo"
+	"torrent-client/peer"
+	"torrent-client/storage"
+)
+
+// seeder is a peer serving data over TCP to a single connection.
+type seeder struct {
+	data        []byte
+	pieceLength int
+	have        peer.Bitfield
+	maxRequests int  // requests answered before hanging up, 0 for no limit
+	stall       bool // ignore requests past maxRequests instead of hanging up
+}
+
+// listen starts s on a loopback port and returns its address.
+func (s seeder) listen(t *testing.T, info metainfo.Info) netip.AddrPort {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		s.serve(c, info)
+	}()
+	return l.Addr().(*net.TCPAddr).AddrPort()
+}
+
+func (s seeder) serve(c net.Conn, info metainfo.Info) {
+	buf := make([]byte, peer.HandshakeSize)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return
+	}
+	var id [20]byte
+	copy(id[:], "-SD0001-")
+	copy(id[8:], c.LocalAddr().String())
+	reply := peer.NewHandshakeMsg(info.Hash(), id)
+	c.Write(reply.ToBytes())
+	c.Write(peer.ToBytes(peer.BitfieldMsg{Bitfield: s.have}))
+
+	frames := peer.NewFrameReader(c)
+	requests := 0
+	for {
+		msg, err := frames.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch m := msg.(type) {
+		case peer.InterestedMsg:
+			c.Write(peer.ToBytes(peer.UnchokeMsg{}))
+		case peer.RequestMsg:
+			if requests++; s.maxRequests > 0 && requests > s.maxRequests {
+				if s.stall {
+					continue
+				}
+				return
+			}
+			start := int(m.Index)*s.pieceLength + int(m.Begin)
+			c.Write(peer.ToBytes(peer.PieceMsg{Index: m.Index, Begin: m.Begin, Block: s.data[start : start+int(m.Length)]}))
+		}
+	}
+}
+
+// testTorrent returns a Torrent for data split into pieces of pieceLength
+// bytes.
+func testTorrent(data []byte, pieceLength int) *Torrent {
+	info := metainfo.Info{Name: "test", Length: len(data), PieceLength: pieceLength}
+	for i := 0; i < len(data); i += pieceLength {
+		hash := sha1.Sum(data[i:min(i+pieceLength, len(data))])
+		info.Pieces = append(info.Pieces, hash[:]...)
+	}
+	t := New(metainfo.MetaInfo{Info: info})
+	t.Storage = storage.NewMemory(info)
+	return t
+}
+
+// all returns a bitfield with the first n of pieces pieces set.
+func all(pieces, n int) peer.Bitfield {
+	b := peer.NewBitfield(pieces)
+	for i := range n {
+		b.Set(i)
+	}
+	return b
+}
+
+func TestDownloadSwarm(t *testing.T) {
+	pieceLength := 2 * int(peer.BLOCK_SIZE)
+	data := make([]byte, 6*pieceLength+100)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	torrent := testTorrent(data, pieceLength)
+	pieces := torrent.Info.NumPieces()
+
+	for _, s := range []seeder{
+		{data: data, pieceLength: pieceLength, have: all(pieces, pieces), maxRequests: 3},
+		{data: data, pieceLength: pieceLength, have: all(pieces, pieces)},
+		{data: data, pieceLength: pieceLength, have: all(pieces, 2)},
+	} {
+		torrent.Tracker.Peers = append(torrent.Tracker.Peers, s.listen(t, torrent.Info))
+	}
+
+	if err := torrent.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := torrent.Download(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, torrent.Storage.(*storage.Memory).Files[0]) {
+		t.Error("downloaded data differs")
+	}
+	if torrent.Status != TorrentFinished || torrent.Left() != 0 {
+		t.Error(torrent.Status, torrent.Left())
+	}
+}
+
+func TestDownloadCorruptSeeder(t *testing.T) {
+	pieceLength := 6 * int(peer.BLOCK_SIZE)
+	data := make([]byte, 4*pieceLength)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	corrupt := bytes.Repeat([]byte{1}, len(data))
+
+	// Pieces mix blocks from both seeders, so only the corrupt one must
+	// be blamed for their failures.
+	for range 30 {
+		torrent := testTorrent(data, pieceLength)
+		for _, s := range []seeder{
+			{data: data, pieceLength: pieceLength, have: all(4, 4)},
+			{data: corrupt, pieceLength: pieceLength, have: all(4, 4)},
+		} {
+			torrent.Tracker.Peers = append(torrent.Tracker.Peers, s.listen(t, torrent.Info))
+		}
+		if err := torrent.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		honest := torrent.Peers[slices.IndexFunc(torrent.Peers, func(c *peer.Conn) bool {
+			return c.Address == torrent.Tracker.Peers[0]
+		})]
+
+		if err := torrent.Download(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if honest.Status != peer.Done {
+			t.Fatal("honest peer disconnected")
+		}
+		if !bytes.Equal(data, torrent.Storage.(*storage.Memory).Files[0]) {
+			t.Fatal("downloaded data differs")
+		}
+	}
+}
+
+func TestDownloadAddPeers(t *testing.T) {
+	pieceLength := int(peer.BLOCK_SIZE)
+	data := make([]byte, 8*pieceLength)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+	torrent := testTorrent(data, pieceLength)
+
+	stalling := seeder{data: data, pieceLength: pieceLength, have: all(8, 8), maxRequests: 2, stall: true}
+	torrent.Tracker.Peers = []netip.AddrPort{stalling.listen(t, torrent.Info)}
+	if err := torrent.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- torrent.Download(ctx) }()
+
+	// A peer found by a later announce finishes the download.
+	other := seeder{data: data, pieceLength: pieceLength, have: all(8, 8)}
+	torrent.AddPeers([]netip.AddrPort{other.listen(t, torrent.Info)})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, torrent.Storage.(*storage.Memory).Files[0]) {
+		t.Error("downloaded data differs")
+	}
+}
+
+func TestDownloadIncomplete(t *testing.T) {
+	pieceLength := int(peer.BLOCK_SIZE)
+	data := make([]byte, 4*pieceLength)
+	torrent := testTorrent(data, pieceLength)
+
+	corrupt := bytes.Repeat([]byte{1}, len(data))
+	for _, s := range []seeder{
+		{data: data, pieceLength: pieceLength, have: all(4, 4), maxRequests: 1},
+		{data: corrupt, pieceLength: pieceLength, have: all(4, 4)},
+	} {
+		torrent.Tracker.Peers = append(torrent.Tracker.Peers, s.listen(t, torrent.Info))
+	}
+
+	if err := torrent.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// The peers left are all gone, so Download waits for new ones until
+	// it times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err := torrent.Download(ctx)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatal(err)
+	}
+	if torrent.Status == TorrentFinished {
+		t.Error(torrent.Status)
+	}
+}
+
+func TestDownloadNoStorage(t *testing.T) {
+	torrent := testTorrent(make([]byte, 10), 4)
+	torrent.Storage = nil
+	if err := torrent.Download(context.Background()); !errors.Is(err, ErrNoStorage) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"fmt"
+)
+
+// WritePiece stores the data of the piece at index.
+func (t *Torrent) WritePiece(index int, data []byte) error {
+	if t.Storage == nil {
+		return ErrNoStorage
+	}
+	if index < 0 || index >= t.Info.NumPieces() {
+		return fmt.Errorf("client: piece %d out of range", index)
+	}
+	if len(data) != t.Info.PieceSize(index) {
+		return fmt.Errorf("client: piece %d has %d bytes, expected %d", index, len(data), t.Info.PieceSize(index))
+	}
+
+	_, err := t.Storage.WriteAt(data, index, 0)
+	return err
+}
+
+// ReadPiece returns the data of the piece at index.
+func (t *Torrent) ReadPiece(index int) ([]byte, error) {
+	if t.Storage == nil {
+		return nil, ErrNoStorage
+	}
+	if index < 0 || index >= t.Info.NumPieces() {
+		return nil, fmt.Errorf("client: piece %d out of range", index)
+	}
+
+	result := make([]byte, t.Info.PieceSize(index))
+	if _, err := t.Storage.ReadAt(result, index, 0); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
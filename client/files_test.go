@@ -0,0 +1,37 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"torrent-client/metainfo"
+	"torrent-client/storage"
+)
+
+func TestPieceSpansFiles(t *testing.T) {
+	m, err := metainfo.Parse([]byte("d4:infod5:filesld6:lengthi3e4:pathl1:aeed6:lengthi0e4:pathl5:empty" +
+		"eed6:lengthi6e4:pathl1:beee4:name3:dir12:piece lengthi4e6:pieces60:" +
+		"aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbccccccccccccccccccccee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := storage.NewMemory(m.Info)
+	torrent := &Torrent{MetaInfo: m, Storage: data}
+
+	for i, piece := range [][]byte{[]byte("0123"), []byte("4567"), []byte("8")} {
+		if err := torrent.WritePiece(i, piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(data.Files[0]) != "012" || string(data.Files[2]) != "345678" {
+		t.Error(data.Files)
+	}
+
+	piece, err := torrent.ReadPiece(0)
+	if err != nil || !bytes.Equal(piece, []byte("0123")) {
+		t.Error(string(piece), err)
+	}
+	if err := torrent.WritePiece(2, []byte("89")); err == nil {
+		t.Error("short final piece must have its exact size")
+	}
+}
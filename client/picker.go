@@ -0,0 +1,422 @@
+package client
+
+import (
+	"crypto/sha1"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"torrent-client/metainfo"
+	"torrent-client/peer"
+)
+
+// picker hands out the blocks of a torrent to the peers downloading it, so
+// that every peer works on what it can provide and the requests of a peer
+// that chokes us or goes away are picked up by the others.
+//
+// New pieces are picked rarest first, from the number of peers known to
+// have each piece, so that pieces few peers have are spread before those
+// peers leave. The very first pieces are picked at random instead, as a
+// complete piece to share is worth more than a rare one at that point.
+//
+// Once every block left is requested, the picker enters endgame mode and
+// hands out blocks already requested from other peers, so that the last
+// pieces do not wait on the slowest peer. The duplicate requests are
+// cancelled when the block arrives.
+//
+// A piece failing its hash check may hold blocks from several peers. If
+// one peer sent all of them, it is banned. Otherwise the blocks each peer
+// sent are remembered, and once the piece passes the peers whose blocks
+// differ from the valid ones are banned.
+type picker struct {
+	mu     sync.Mutex
+	info   metainfo.Info
+	have   peer.Bitfield         // verified pieces
+	left   int                   // pieces not verified yet
+	active map[int]*partialPiece // pieces being downloaded
+	order  []int                 // keys of active, oldest first
+	done   chan struct{}         // closed once every piece is verified
+	wake   chan struct{}         // closed when blocks become available
+
+	availability []int                        // per piece, the peers having it
+	counted      map[*peer.Conn]peer.Bitfield // pieces counted in availability
+	randomFirst  int                          // pieces picked at random
+
+	suspects map[int][]suspect  // per failed piece, the blocks received
+	bans     map[*peer.Conn]int // banned peers, with a piece they corrupted
+}
+
+// suspect is a block of a piece that failed its hash check.
+type suspect struct {
+	conn  *peer.Conn
+	block int
+	hash  [20]byte
+}
+
+// randomFirstPieces is the number of pieces picked at random before
+// switching to rarest first.
+const randomFirstPieces = 4
+
+// partialPiece is a piece being downloaded.
+type partialPiece struct {
+	data     []byte
+	owners   [][]*peer.Conn // per block, the peers it is requested from
+	senders  []*peer.Conn   // per block, the peer it was received from
+	received []bool
+	left     int // blocks not received yet
+}
+
+// newPicker returns a picker for the pieces of info missing from have.
+func newPicker(info metainfo.Info, have peer.Bitfield) *picker {
+	p := &picker{
+		info:   info,
+		have:   peer.NewBitfield(info.NumPieces()),
+		left:   info.NumPieces() - have.Count(),
+		active: make(map[int]*partialPiece),
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}),
+
+		availability: make([]int, info.NumPieces()),
+		counted:      make(map[*peer.Conn]peer.Bitfield),
+		randomFirst:  randomFirstPieces,
+
+		suspects: make(map[int][]suspect),
+		bans:     make(map[*peer.Conn]int),
+	}
+	copy(p.have, have)
+	if p.left == 0 {
+		close(p.done)
+	}
+	return p
+}
+
+// complete reports whether every piece was downloaded and verified.
+func (p *picker) complete() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.left == 0
+}
+
+// changed returns a channel closed the next time blocks handed out
+// earlier become available again.
+func (p *picker) changed() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.wake
+}
+
+// notify wakes the peers waiting on changed.
+func (p *picker) notify() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// update counts the pieces conn announced in a bitfield or Have messages
+// since the last call.
+func (p *picker) update(conn *peer.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counted, ok := p.counted[conn]
+	if !ok {
+		counted = peer.NewBitfield(p.info.NumPieces())
+		p.counted[conn] = counted
+	}
+	for index := range p.info.NumPieces() {
+		if !counted.Has(index) && conn.HasPiece(index) {
+			counted.Set(index)
+			p.availability[index]++
+		}
+	}
+}
+
+// leave gives up the blocks requested from conn, which went away, and
+// forgets the pieces it had.
+func (p *picker) leave(conn *peer.Conn) {
+	p.release(conn)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counted := p.counted[conn]
+	for index := range p.info.NumPieces() {
+		if counted.Has(index) {
+			p.availability[index]--
+		}
+	}
+	delete(p.counted, conn)
+}
+
+// interesting reports whether conn has a piece we still need.
+func (p *picker) interesting(conn *peer.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for index := range p.info.NumPieces() {
+		if !p.have.Has(index) && conn.HasPiece(index) {
+			return true
+		}
+	}
+	return false
+}
+
+// next picks a block for conn to request: preferably one from a piece
+// already started, so that pieces complete quickly, otherwise the first
+// block of a new piece conn has, chosen by pick, and in endgame mode a
+// block requested from other peers only.
+func (p *picker) next(conn *peer.Conn) (peer.Block, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, banned := p.bans[conn]; banned {
+		return peer.Block{}, false
+	}
+	for _, index := range p.order {
+		if !conn.HasPiece(index) {
+			continue
+		}
+		piece := p.active[index]
+		for i, owners := range piece.owners {
+			if !piece.received[i] && len(owners) == 0 {
+				return p.assignFree(conn, index, i), true
+			}
+		}
+	}
+
+	if index, ok := p.pick(conn); ok {
+		p.start(index)
+		return p.assignFree(conn, index, 0), true
+	}
+
+	if !p.endgame() {
+		return peer.Block{}, false
+	}
+	for _, index := range p.order {
+		if !conn.HasPiece(index) {
+			continue
+		}
+		piece := p.active[index]
+		for i, owners := range piece.owners {
+			if !piece.received[i] && !slices.Contains(owners, conn) {
+				return p.assign(conn, index, i), true
+			}
+		}
+	}
+	return peer.Block{}, false
+}
+
+// endgame reports whether every block left is requested from some peer.
+func (p *picker) endgame() bool {
+	if len(p.active) != p.left {
+		return false
+	}
+	for _, piece := range p.active {
+		for i, owners := range piece.owners {
+			if !piece.received[i] && len(owners) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pick chooses a piece conn has that is neither verified nor started: at
+// random among them for the first pieces, and then among the rarest ones.
+func (p *picker) pick(conn *peer.Conn) (int, bool) {
+	random := p.info.NumPieces()-p.left < p.randomFirst
+	result, candidates := -1, 0
+	for index := range p.info.NumPieces() {
+		if p.have.Has(index) || p.active[index] != nil || !conn.HasPiece(index) {
+			continue
+		}
+		if !random && result >= 0 && p.availability[index] > p.availability[result] {
+			continue
+		}
+		if !random && result >= 0 && p.availability[index] < p.availability[result] {
+			candidates = 0
+		}
+		// Keep each candidate with equal probability.
+		if candidates++; rand.IntN(candidates) == 0 {
+			result = index
+		}
+	}
+	return result, result >= 0
+}
+
+// start begins downloading the piece at index.
+func (p *picker) start(index int) {
+	size := p.info.PieceSize(index)
+	blocks := (size + int(peer.BLOCK_SIZE) - 1) / int(peer.BLOCK_SIZE)
+	p.active[index] = &partialPiece{
+		data:     make([]byte, size),
+		owners:   make([][]*peer.Conn, blocks),
+		senders:  make([]*peer.Conn, blocks),
+		received: make([]bool, blocks),
+		left:     blocks,
+	}
+	p.order = append(p.order, index)
+}
+
+// assignFree assigns block i of the piece at index, requested from no
+// peer yet, to conn. If it was the last such block, endgame mode starts
+// and the peers that ran out of blocks are woken up to duplicate requests.
+func (p *picker) assignFree(conn *peer.Conn, index, i int) peer.Block {
+	block := p.assign(conn, index, i)
+	if p.endgame() {
+		p.notify()
+	}
+	return block
+}
+
+// assign records that block i of the piece at index is requested from
+// conn and returns it.
+func (p *picker) assign(conn *peer.Conn, index, i int) peer.Block {
+	piece := p.active[index]
+	piece.owners[i] = append(piece.owners[i], conn)
+	begin := i * int(peer.BLOCK_SIZE)
+	return peer.Block{
+		Index:  uint32(index),
+		Begin:  uint32(begin),
+		Length: uint32(min(int(peer.BLOCK_SIZE), len(piece.data)-begin)),
+	}
+}
+
+// received stores a block sent by conn and returns the other peers it was
+// requested from, whose requests should be cancelled. Once the last block
+// of its piece is in, it also returns the piece's data to be verified and
+// passed to finish. Blocks that were not asked for, or were already
+// received, are ignored.
+func (p *picker) received(conn *peer.Conn, m peer.PieceMsg) (data []byte, complete bool, cancel []*peer.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	piece := p.active[int(m.Index)]
+	if piece == nil || m.Begin%peer.BLOCK_SIZE != 0 || int(m.Begin) >= len(piece.data) {
+		return nil, false, nil
+	}
+	i := int(m.Begin / peer.BLOCK_SIZE)
+	if piece.received[i] || len(m.Block) != min(int(peer.BLOCK_SIZE), len(piece.data)-int(m.Begin)) {
+		return nil, false, nil
+	}
+
+	copy(piece.data[m.Begin:], m.Block)
+	cancel = slices.DeleteFunc(piece.owners[i], func(c *peer.Conn) bool { return c == conn })
+	piece.received[i] = true
+	piece.senders[i] = conn
+	piece.owners[i] = nil
+	piece.left--
+	if len(cancel) > 0 {
+		// The peers cancelled have room for other requests.
+		p.notify()
+	}
+	return piece.data, piece.left == 0, cancel
+}
+
+// finish ends the download of the piece at index, whose blocks were all
+// received. If its data was valid it is marked as present, otherwise the
+// peers that sent it are suspected or banned and it will be downloaded
+// again.
+func (p *picker) finish(index int, valid bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	piece := p.active[index]
+	if !valid {
+		p.blame(index, piece)
+		p.drop(index)
+		return
+	}
+	p.clear(index, piece)
+	p.delete(index)
+	if p.have.Has(index) {
+		return
+	}
+	p.have.Set(index)
+	p.left--
+	if p.left == 0 {
+		close(p.done)
+	}
+}
+
+// retry downloads the piece at index again, without blaming the peers
+// that sent it.
+func (p *picker) retry(index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.drop(index)
+}
+
+// drop forgets the piece at index, waking up the peers that may download
+// it again.
+func (p *picker) drop(index int) {
+	p.delete(index)
+	p.notify()
+}
+
+// delete forgets the piece at index.
+func (p *picker) delete(index int) {
+	delete(p.active, index)
+	p.order = slices.DeleteFunc(p.order, func(i int) bool { return i == index })
+}
+
+// blame bans the peer that sent every block of piece, which failed its
+// hash check, or else remembers the blocks each peer sent for clear.
+func (p *picker) blame(index int, piece *partialPiece) {
+	if senders := slices.Compact(slices.Clone(piece.senders)); len(senders) == 1 {
+		p.ban(senders[0], index)
+		return
+	}
+	for i, conn := range piece.senders {
+		p.suspects[index] = append(p.suspects[index], suspect{conn, i, sha1.Sum(piece.block(i))})
+	}
+}
+
+// clear bans the peers whose blocks in earlier, failed downloads of the
+// piece at index differ from those of piece, which is valid.
+func (p *picker) clear(index int, piece *partialPiece) {
+	for _, s := range p.suspects[index] {
+		if sha1.Sum(piece.block(s.block)) != s.hash {
+			p.ban(s.conn, index)
+		}
+	}
+	delete(p.suspects, index)
+}
+
+// ban stops handing out blocks to conn, which sent corrupt data for the
+// piece at index, and wakes it up to notice.
+func (p *picker) ban(conn *peer.Conn, index int) {
+	if _, banned := p.bans[conn]; !banned {
+		p.bans[conn] = index
+		p.notify()
+	}
+}
+
+// banned reports whether conn was banned, and for which piece.
+func (p *picker) banned(conn *peer.Conn) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	index, banned := p.bans[conn]
+	return index, banned
+}
+
+// block returns the data of block i.
+func (piece *partialPiece) block(i int) []byte {
+	begin := i * int(peer.BLOCK_SIZE)
+	return piece.data[begin:min(begin+int(peer.BLOCK_SIZE), len(piece.data))]
+}
+
+// release gives up every block requested from conn, so that other peers
+// can request them.
+func (p *picker) release(conn *peer.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	released := false
+	for _, piece := range p.active {
+		for i, owners := range piece.owners {
+			piece.owners[i] = slices.DeleteFunc(owners, func(c *peer.Conn) bool { return c == conn })
+			released = released || len(piece.owners[i]) != len(owners)
+		}
+	}
+	if released {
+		p.notify()
+	}
+}
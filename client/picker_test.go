@@ -0,0 +1,157 @@
+package client
+
+import (
+	"slices"
+	"testing"
+
+	"torrent-client/peer"
+)
+
+// dial connects to a seeder having the pieces in have and reads its
+// bitfield.
+func dial(t *testing.T, torrent *Torrent, have peer.Bitfield) *peer.Conn {
+	s := seeder{pieceLength: torrent.Info.PieceLength, have: have}
+	conn, err := peer.Dial(s.listen(t, torrent.Info), torrent.InfoHash(), torrent.PeerID, torrent.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := conn.ReadPeerMsg(); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// pieces returns a bitfield for up to 4 pieces with the given ones set.
+func pieces(indexes ...int) peer.Bitfield {
+	b := peer.NewBitfield(4)
+	for _, i := range indexes {
+		b.Set(i)
+	}
+	return b
+}
+
+func TestPickRarestFirst(t *testing.T) {
+	torrent := testTorrent(make([]byte, 4*int(peer.BLOCK_SIZE)), int(peer.BLOCK_SIZE))
+	a := dial(t, torrent, pieces(0, 1, 2, 3))
+	b := dial(t, torrent, pieces(0, 1))
+	c := dial(t, torrent, pieces(0, 1, 3))
+
+	p := newPicker(torrent.Info, nil)
+	p.randomFirst = 0
+	for _, conn := range []*peer.Conn{a, b, c} {
+		p.update(conn)
+		p.update(conn)
+	}
+	if expected := []int{3, 3, 1, 2}; !slices.Equal(p.availability, expected) {
+		t.Fatal(p.availability, "expected", expected)
+	}
+
+	if block, ok := p.next(a); !ok || block.Index != 2 {
+		t.Error(block, ok)
+	}
+
+	// Once c leaves, piece 3 is as rare as piece 2 was.
+	p.leave(c)
+	if expected := []int{2, 2, 1, 1}; !slices.Equal(p.availability, expected) {
+		t.Fatal(p.availability, "expected", expected)
+	}
+	if block, ok := p.next(a); !ok || block.Index != 3 {
+		t.Error(block, ok)
+	}
+	if block, ok := p.next(b); !ok || block.Index > 1 {
+		t.Error(block, ok)
+	}
+}
+
+func TestPickRandom(t *testing.T) {
+	torrent := testTorrent(make([]byte, 4*int(peer.BLOCK_SIZE)), int(peer.BLOCK_SIZE))
+	a := dial(t, torrent, pieces(0, 1, 2, 3))
+
+	for _, randomFirst := range []int{randomFirstPieces, 0} {
+		// Every piece is as rare as the others, so ties are broken at
+		// random when not picking at random anyway.
+		picked := make(map[uint32]bool)
+		for range 100 {
+			p := newPicker(torrent.Info, nil)
+			p.randomFirst = randomFirst
+			p.update(a)
+			block, _ := p.next(a)
+			picked[block.Index] = true
+		}
+		if len(picked) < 2 {
+			t.Error("random first", randomFirst, "always picked", picked)
+		}
+	}
+}
+
+func TestEndgame(t *testing.T) {
+	torrent := testTorrent(make([]byte, 3*int(peer.BLOCK_SIZE)), int(peer.BLOCK_SIZE))
+	a := dial(t, torrent, pieces(0, 1))
+	b := dial(t, torrent, pieces(0, 1))
+	c := dial(t, torrent, pieces(2))
+
+	p := newPicker(torrent.Info, nil)
+	requested := make(map[uint32]bool)
+	for range 2 {
+		block, ok := p.next(a)
+		if !ok {
+			t.Fatal("a should get pieces 0 and 1")
+		}
+		requested[block.Index] = true
+	}
+	if block, ok := p.next(b); ok {
+		t.Fatal("duplicate request before piece 2 is requested:", block)
+	}
+
+	if block, ok := p.next(c); !ok || block.Index != 2 {
+		t.Fatal(block, ok)
+	}
+	for range 2 {
+		block, ok := p.next(b)
+		if !ok || !requested[block.Index] {
+			t.Fatal(block, ok)
+		}
+		delete(requested, block.Index)
+	}
+	if block, ok := p.next(b); ok {
+		t.Error("no block left to duplicate:", block)
+	}
+
+	m := peer.PieceMsg{Index: 0, Begin: 0, Block: make([]byte, peer.BLOCK_SIZE)}
+	if _, complete, cancel := p.received(b, m); !complete || !slices.Equal(cancel, []*peer.Conn{a}) {
+		t.Error(complete, cancel)
+	}
+	if _, complete, cancel := p.received(a, m); complete || cancel != nil {
+		t.Error("late duplicate:", complete, cancel)
+	}
+}
+
+func TestEndgameWakesIdlePeers(t *testing.T) {
+	torrent := testTorrent(make([]byte, 2*int(peer.BLOCK_SIZE)), int(peer.BLOCK_SIZE))
+	a := dial(t, torrent, pieces(0))
+	b := dial(t, torrent, pieces(0))
+	c := dial(t, torrent, pieces(1))
+
+	p := newPicker(torrent.Info, nil)
+	if block, ok := p.next(a); !ok || block.Index != 0 {
+		t.Fatal(block, ok)
+	}
+	// b runs out of blocks while piece 1 is not requested yet.
+	changed := p.changed()
+	if block, ok := p.next(b); ok {
+		t.Fatal(block)
+	}
+
+	if block, ok := p.next(c); !ok || block.Index != 1 {
+		t.Fatal(block, ok)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("idle peer not woken up when endgame starts")
+	}
+	if block, ok := p.next(b); !ok || block.Index != 0 {
+		t.Error(block, ok)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"torrent-client/bencode"
+	"torrent-client/peer"
+	"torrent-client/storage"
+)
+
+// ErrResumeStale is returned when a resume file does not match the
+// torrent or the files on disk.
+var ErrResumeStale = errors.New("client: resume data out of date")
+
+// Resume is the progress of a download saved between runs, stored
+// bencoded in a file next to the data.
+type Resume struct {
+	InfoHash   []byte
+	Pieces     []byte // bitfield of the verified pieces
+	Files      []ResumeFile
+	Uploaded   int
+	Downloaded int
+}
+
+// ResumeFile is the state of one entry of Info.FileList when the resume
+// data was saved. Padding files are left zero.
+type ResumeFile struct {
+	Length int
+	Mtime  int64 // modification time, in nanoseconds since the epoch
+}
+
+// ResumePath returns the path of the resume file for a download into dir.
+func (t *Torrent) ResumePath(dir string) string {
+	return filepath.Join(dir, t.Info.Name+".resume")
+}
+
+// SaveResume records the pieces verified so far and the transfer counters
+// in the resume file for a download into dir, after flushing the pieces to
+// storage. It may be called while downloading: pieces written after the
+// flush change the file times, so that the next LoadResume verifies them.
+func (t *Torrent) SaveResume(dir string) error {
+	if t.Storage == nil {
+		return ErrNoStorage
+	}
+	hash := t.InfoHash()
+	r := Resume{
+		InfoHash:   hash[:],
+		Pieces:     t.Have(),
+		Uploaded:   int(t.uploaded.Load()),
+		Downloaded: int(t.downloaded.Load()),
+	}
+	if err := t.Storage.Flush(); err != nil {
+		return err
+	}
+	var err error
+	if r.Files, err = t.stat(dir); err != nil {
+		return err
+	}
+
+	data, err := bencode.Marshal(r)
+	if err != nil {
+		return err
+	}
+	// Replace the previous file at once, so that a crash leaves either.
+	path := t.ResumePath(dir)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// OpenFiles sets the files of the torrent under dir, created as needed, as
+// its Storage, and restores the progress of an earlier download into dir:
+// from its resume file by LoadResume, or else by verifying data already
+// there. ErrResumeStale only tells that the resume file could not be
+// trusted.
+func (t *Torrent) OpenFiles(dir string) error {
+	_, err := os.Stat(filepath.Join(dir, t.Info.Name))
+	existed := err == nil
+
+	files, err := storage.OpenFile(t.Info, dir)
+	if err != nil {
+		return err
+	}
+	t.Storage = files
+	if err := t.LoadResume(dir); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if existed {
+		// Left by a run that stopped before saving its progress.
+		return t.Verify()
+	}
+	return nil
+}
+
+// LoadResume restores the progress saved in the resume file for a
+// download into dir. The pieces it lists are trusted if the files have
+// the sizes and modification times recorded; otherwise every piece is
+// hashed again by Verify, and ErrResumeStale is returned once done. An
+// error matching os.ErrNotExist means there is nothing to resume.
+func (t *Torrent) LoadResume(dir string) error {
+	data, err := os.ReadFile(t.ResumePath(dir))
+	if err != nil {
+		return err
+	}
+	var r Resume
+	hash := t.InfoHash()
+	if err := bencode.Unmarshal(data, &r); err != nil || string(r.InfoHash) != string(hash[:]) {
+		return t.reverify("invalid resume file")
+	}
+	t.uploaded.Store(int64(r.Uploaded))
+	t.downloaded.Store(int64(r.Downloaded))
+
+	files, err := t.stat(dir)
+	if err != nil || len(r.Pieces) != len(t.have) || !slices.Equal(r.Files, files) {
+		return t.reverify("files changed")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	copy(t.have, r.Pieces)
+	return nil
+}
+
+// reverify falls back to Verify when the resume file cannot be trusted
+// for the given reason.
+func (t *Torrent) reverify(reason string) error {
+	if err := t.Verify(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s, pieces verified again", ErrResumeStale, reason)
+}
+
+// stat returns the state of the files of a download into dir.
+func (t *Torrent) stat(dir string) ([]ResumeFile, error) {
+	var result []ResumeFile
+	for _, f := range t.Info.FileList() {
+		if f.IsPadding() {
+			result = append(result, ResumeFile{})
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, f.FilePath()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ResumeFile{Length: int(info.Size()), Mtime: info.ModTime().UnixNano()})
+	}
+	return result, nil
+}
+
+// Verify hashes every piece in storage and records which ones are valid.
+func (t *Torrent) Verify() error {
+	have := peer.NewBitfield(t.Info.NumPieces())
+	for index := range t.Info.NumPieces() {
+		piece, err := t.ReadPiece(index)
+		if err != nil {
+			return err
+		}
+		if sha1.Sum(piece) == t.Info.PieceHash(index) {
+			have.Set(index)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.have = have
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"torrent-client/storage"
+)
+
+// openResumed returns a Torrent for data stored under dir, with the
+// progress of its resume file loaded.
+func openResumed(t *testing.T, data []byte, dir string) (*Torrent, error) {
+	torrent := testTorrent(data, 4)
+	files, err := storage.OpenFile(torrent.Info, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { files.Close() })
+	torrent.Storage = files
+	return torrent, torrent.LoadResume(dir)
+}
+
+func TestResume(t *testing.T) {
+	data := []byte("0123456789")
+	dir := t.TempDir()
+
+	torrent, err := openResumed(t, data, dir)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	for _, index := range []int{0, 2} {
+		if err := torrent.WritePiece(index, data[index*4:min(index*4+4, len(data))]); err != nil {
+			t.Fatal(err)
+		}
+		torrent.setHave(index)
+	}
+	torrent.downloaded.Store(6)
+	torrent.uploaded.Store(3)
+	if err := torrent.Storage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := torrent.SaveResume(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	torrent, err = openResumed(t, data, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !torrent.Have().Has(0) || torrent.Have().Has(1) || !torrent.Have().Has(2) {
+		t.Error(torrent.Have())
+	}
+	if stats := torrent.Stats(); stats.Downloaded != 6 || stats.Uploaded != 3 || stats.Left != 4 {
+		t.Error(stats)
+	}
+
+	// Damage piece 2 and complete piece 1 behind the resume file's back.
+	path := filepath.Join(dir, "test")
+	if err := os.WriteFile(path, []byte("012345678x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	torrent, err = openResumed(t, data, dir)
+	if !errors.Is(err, ErrResumeStale) {
+		t.Fatal(err)
+	}
+	if !slices.Equal(torrent.Have(), []byte{0xc0}) || torrent.Left() != 2 {
+		t.Error(torrent.Have(), torrent.Left())
+	}
+
+	if err := os.WriteFile(torrent.ResumePath(dir), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openResumed(t, data, dir); !errors.Is(err, ErrResumeStale) {
+		t.Error(err)
+	}
+}
+
+func TestOpenFilesWithoutResume(t *testing.T) {
+	data := []byte("0123456789")
+	dir := t.TempDir()
+
+	torrent := testTorrent(data, 4)
+	if err := torrent.OpenFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { torrent.Storage.Close() })
+	if torrent.Left() != len(data) {
+		t.Error(torrent.Left())
+	}
+
+	// Data left by a run killed before saving its progress is verified.
+	if err := os.WriteFile(filepath.Join(dir, "test"), []byte("0123x56789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	torrent = testTorrent(data, 4)
+	if err := torrent.OpenFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { torrent.Storage.Close() })
+	if !slices.Equal(torrent.Have(), []byte{0xa0}) || torrent.Left() != 4 {
+		t.Error(torrent.Have(), torrent.Left())
+	}
+}
@@ -0,0 +1,411 @@
+// Package client ties the metainfo, tracker and peer packages together into
+// a downloading session for a single torrent.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"torrent-client/metainfo"
+	"torrent-client/peer"
+	"torrent-client/storage"
+	"torrent-client/tracker"
+)
+
+// Torrent is a torrent being downloaded.
+type Torrent struct {
+	metainfo.MetaInfo
+	Status   TorrentStatus
+	Path     string
+	Trackers *tracker.List
+	Session  *tracker.Session
+	Tracker  tracker.Response
+	Peers    []*peer.Conn
+	PeerID   [20]byte
+	Port     uint16
+
+	// Storage holds the downloaded data. It must be set before
+	// downloading.
+	Storage storage.Storage
+
+	uploaded   atomic.Int64
+	downloaded atomic.Int64
+
+	mu      sync.Mutex
+	have    peer.Bitfield           // verified pieces
+	swarm   *swarm                  // while Download runs
+	dialing map[netip.AddrPort]bool // peers being connected by AddPeers
+}
+
+// peerIDPrefix starts every peer ID we generate, in the Azureus style of
+// client code and version.
+const peerIDPrefix = "-TC0001-"
+
+// DefaultPort is the port announced to trackers.
+const DefaultPort = 6881
+
+// TorrentStatus is the state of a download.
+type TorrentStatus uint8
+
+const (
+	TorrentIdle TorrentStatus = iota
+	TorrentActive
+	TorrentConnecting
+	TorrentFinished
+)
+
+// ErrNoPeers is returned when no peer could be found or reached.
+var ErrNoPeers = errors.New("client: no peers available")
+
+// ErrNoStorage is returned when downloading a Torrent without Storage.
+var ErrNoStorage = errors.New("client: no storage")
+
+// ErrIncomplete is returned when every peer went away before the download
+// completed.
+var ErrIncomplete = errors.New("client: download incomplete")
+
+// Open loads the torrent file stored at path.
+func Open(path string) (*Torrent, error) {
+	info, err := metainfo.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := New(info)
+	t.Path = path
+	return t, nil
+}
+
+// New returns a Torrent downloading the torrent described by info, with a
+// fresh peer ID. Its Storage is left for the caller to set.
+func New(info metainfo.MetaInfo) *Torrent {
+	t := &Torrent{
+		MetaInfo: info,
+		Status:   TorrentIdle,
+		Trackers: tracker.NewList(info.Trackers()),
+		Port:     DefaultPort,
+		have:     peer.NewBitfield(info.Info.NumPieces()),
+	}
+	copy(t.PeerID[:], peerIDPrefix)
+	rand.Read(t.PeerID[len(peerIDPrefix):])
+	t.Session = tracker.NewSession(t.Trackers, t.InfoHash(), t.PeerID, t.Port, t.Stats)
+	t.Session.IPv6 = localIPv6()
+	return t
+}
+
+// Stats returns the transfer counters reported to trackers.
+func (t *Torrent) Stats() tracker.Stats {
+	return tracker.Stats{
+		Uploaded:   int(t.uploaded.Load()),
+		Downloaded: int(t.downloaded.Load()),
+		Left:       t.Left(),
+	}
+}
+
+// Left returns the number of bytes still to download.
+func (t *Torrent) Left() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	left := 0
+	for index := range t.Info.NumPieces() {
+		if !t.have.Has(index) {
+			left += t.Info.PieceSize(index)
+		}
+	}
+	return left
+}
+
+// Have returns the pieces downloaded and verified so far.
+func (t *Torrent) Have() peer.Bitfield {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return bytes.Clone(t.have)
+}
+
+// setHave records that the piece at index is verified.
+func (t *Torrent) setHave(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.have.Set(index)
+}
+
+// DiscoverPeers sends the started announce to the torrent's trackers and
+// stores the peers returned by the first one to answer.
+func (t *Torrent) DiscoverPeers(ctx context.Context) error {
+	resp, err := t.Session.Announce(ctx, tracker.EventStarted)
+	if err != nil {
+		return err
+	}
+	t.Tracker = resp
+
+	if len(t.Tracker.Peers) == 0 {
+		return ErrNoPeers
+	}
+	return nil
+}
+
+// Connect dials every peer returned by the tracker, alternating between
+// IPv6 and IPv4 peers. Peers that cannot be reached are skipped; an error
+// is only returned if none could be.
+func (t *Torrent) Connect() error {
+	t.Status = TorrentConnecting
+	var errs []error
+	connected := 0
+	for _, i := range interleave(t.Tracker.Peers) {
+		conn, err := peer.Dial(i, t.InfoHash(), t.PeerID, t.Info)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t.mu.Lock()
+		t.Peers = append(t.Peers, conn)
+		t.mu.Unlock()
+		connected++
+	}
+
+	if connected == 0 {
+		return errors.Join(append([]error{ErrNoPeers}, errs...)...)
+	}
+	return nil
+}
+
+// AddPeers connects in the background to the peers in addrs that are
+// neither connected nor being dialled, such as those returned by
+// re-announces. While Download runs, they join it. Peers that cannot be
+// reached are skipped.
+func (t *Torrent) AddPeers(addrs []netip.AddrPort) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, addr := range addrs {
+		if t.dialing[addr] || slices.ContainsFunc(t.Peers, func(c *peer.Conn) bool { return c.Address == addr }) {
+			continue
+		}
+		if t.dialing == nil {
+			t.dialing = make(map[netip.AddrPort]bool)
+		}
+		t.dialing[addr] = true
+		go t.dial(addr)
+	}
+}
+
+// dial connects to the peer at addr for AddPeers.
+func (t *Torrent) dial(addr netip.AddrPort) {
+	conn, err := peer.Dial(addr, t.InfoHash(), t.PeerID, t.Info)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.dialing, addr)
+	if err != nil {
+		return
+	}
+	t.Peers = append(t.Peers, conn)
+	if t.swarm != nil {
+		t.start(t.swarm, conn)
+	}
+}
+
+// Download fetches the whole torrent from the connected peers, each of
+// which is handed blocks of the pieces it has by a shared picker. Peers
+// that fail, or that the picker bans for sending corrupt data, are marked
+// as disconnected and their blocks handed to the others. Peers connected
+// by AddPeers while it runs join in, so Download keeps waiting for them
+// even once every peer failed. It returns once every piece is in, or with
+// ErrIncomplete joined with ctx's error and those of the peers once ctx is
+// done; in both cases the remaining connections are closed.
+func (t *Torrent) Download(ctx context.Context) error {
+	if t.Storage == nil {
+		return ErrNoStorage
+	}
+	t.Status = TorrentActive
+	s := &swarm{picker: newPicker(t.Info, t.Have())}
+
+	t.mu.Lock()
+	t.swarm = s
+	for _, i := range t.Peers {
+		if i.Status != peer.Disconnected {
+			t.start(s, i)
+		}
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-s.picker.done:
+	case <-ctx.Done():
+	}
+	t.mu.Lock()
+	t.swarm = nil
+	t.mu.Unlock()
+	// Close first, to wake up workers stuck writing to peers that stopped
+	// reading.
+	s.stopping.Store(true)
+	t.Close()
+	s.wg.Wait()
+
+	if !s.picker.complete() {
+		return errors.Join(append([]error{ErrIncomplete, ctx.Err()}, s.errs...)...)
+	}
+	t.mu.Lock()
+	for _, i := range t.Peers {
+		if i.Status == peer.Active {
+			i.Status = peer.Done
+		}
+	}
+	t.mu.Unlock()
+	t.Status = TorrentFinished
+	return nil
+}
+
+// swarm is the state of a running Download.
+type swarm struct {
+	picker   *picker
+	wg       sync.WaitGroup
+	stopping atomic.Bool // set once Download closes the connections
+	errs     []error     // guarded by Torrent.mu
+}
+
+// start runs a worker downloading from conn as part of s. t.mu must be
+// held.
+func (t *Torrent) start(s *swarm, conn *peer.Conn) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		conn.Status = peer.Active
+		err := t.download(conn, s.picker)
+		if _, banned := s.picker.banned(conn); !banned && (s.picker.complete() || s.stopping.Load()) {
+			// Closing the connections when stopping is no failure.
+			err = nil
+		}
+		if err != nil {
+			conn.Status = peer.Disconnected
+			conn.Close()
+			t.mu.Lock()
+			s.errs = append(s.errs, err)
+			t.mu.Unlock()
+		}
+	}()
+}
+
+// received is a message read from a peer, or the error that ended the
+// connection.
+type received struct {
+	msg peer.Message
+	err error
+}
+
+// download exchanges messages with conn until the torrent is complete or
+// the connection fails, requesting the blocks p hands out whenever conn is
+// unchoked and its queue has room.
+func (t *Torrent) download(conn *peer.Conn, p *picker) error {
+	defer p.leave(conn)
+
+	// Messages are read on their own goroutine so that we can also wake up
+	// when blocks given up by other peers become available.
+	msgs := make(chan received)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			msg, err := conn.ReadPeerMsg()
+			if m, ok := msg.(peer.PieceMsg); ok {
+				// The block is only valid until the next read.
+				m.Block = bytes.Clone(m.Block)
+				msg = m
+			}
+			select {
+			case msgs <- received{msg, err}:
+			case <-quit:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		changed := p.changed()
+		if index, banned := p.banned(conn); banned {
+			return &peer.HashError{Addr: conn.Address.String(), Index: index}
+		}
+		if interested := p.interesting(conn); interested != conn.State().AmInterested {
+			var msg peer.Message = peer.NotInterestedMsg{}
+			if interested {
+				msg = peer.InterestedMsg{}
+			}
+			if err := conn.Send(msg); err != nil {
+				return err
+			}
+		}
+
+		if state := conn.State(); state.AmInterested && !state.PeerChoking {
+			for conn.Outstanding() < conn.QueueDepth() {
+				block, ok := p.next(conn)
+				if !ok {
+					break
+				}
+				if err := conn.Request(block); err != nil {
+					return err
+				}
+			}
+		}
+
+		var r received
+		select {
+		case <-p.done:
+			return nil
+		case <-changed:
+			continue
+		case r = <-msgs:
+		}
+		if r.err != nil {
+			return r.err
+		}
+
+		switch m := r.msg.(type) {
+		case peer.BitfieldMsg, peer.HaveMsg:
+			p.update(conn)
+		case peer.ChokeMsg:
+			p.release(conn)
+		case peer.PieceMsg:
+			piece, complete, cancel := p.received(conn, m)
+			block := peer.Block{Index: m.Index, Begin: m.Begin, Length: uint32(len(m.Block))}
+			for _, other := range cancel {
+				// A failure shows up when other's own worker reads from it.
+				other.Cancel(block)
+			}
+			if !complete {
+				continue
+			}
+			index := int(m.Index)
+			if sha1.Sum(piece) != t.Info.PieceHash(index) {
+				// The peers to blame are banned by the picker.
+				p.finish(index, false)
+				continue
+			}
+			if err := t.WritePiece(index, piece); err != nil {
+				p.retry(index)
+				return err
+			}
+			t.setHave(index)
+			t.downloaded.Add(int64(len(piece)))
+			p.finish(index, true)
+		}
+	}
+}
+
+// Close closes every peer connection.
+func (t *Torrent) Close() {
+	t.mu.Lock()
+	peers := slices.Clone(t.Peers)
+	t.mu.Unlock()
+	for _, i := range peers {
+		i.Close()
+	}
+}
@@ -0,0 +1,150 @@
+package metainfo
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// File is one entry of a multi-file torrent.
+type File struct {
+	Length int
+	Path   []string
+	Md5sum string `bencoded:",omitempty"`
+	Attr   string `bencoded:",omitempty"`
+}
+
+// Segment is the part of one file covered by a range of the torrent's
+// data.
+type Segment struct {
+	File   int // index into FileList
+	Offset int // offset within the file
+	Length int
+}
+
+// IsMultiFile reports whether the torrent describes a directory of files.
+func (info Info) IsMultiFile() bool {
+	return len(info.Files) > 0
+}
+
+// FileList returns the files making up the torrent's data, in order. Paths
+// are relative to the download directory: a single-file torrent holds one
+// file called Name, a multi-file torrent places its files under Name.
+func (info Info) FileList() []File {
+	if !info.IsMultiFile() {
+		return []File{{Length: info.Length, Path: []string{info.Name}, Md5sum: info.Md5sum}}
+	}
+
+	result := make([]File, len(info.Files))
+	for i, f := range info.Files {
+		result[i] = f
+		result[i].Path = append([]string{info.Name}, f.Path...)
+	}
+	return result
+}
+
+// FilePath joins the path components of f into a relative path for the
+// current operating system.
+func (f File) FilePath() string {
+	return filepath.Join(f.Path...)
+}
+
+// IsPadding reports whether f is a BEP 47 padding file.
+func (f File) IsPadding() bool {
+	return slices.Contains([]byte(f.Attr), 'p')
+}
+
+// TotalLength returns the size of the torrent's data in bytes.
+func (info Info) TotalLength() int {
+	if !info.IsMultiFile() {
+		return info.Length
+	}
+
+	total := 0
+	for _, f := range info.Files {
+		total += f.Length
+	}
+	return total
+}
+
+// NumPieces returns the number of pieces the data is split into.
+func (info Info) NumPieces() int {
+	return len(info.Pieces) / 20
+}
+
+// PieceHash returns the SHA-1 hash of the piece at index.
+func (info Info) PieceHash(index int) (hash [20]byte) {
+	copy(hash[:], info.Pieces[index*20:index*20+20])
+	return
+}
+
+// PieceSize returns the length of the piece at index; only the last piece
+// may be shorter than PieceLength.
+func (info Info) PieceSize(index int) int {
+	if index == info.NumPieces()-1 {
+		if last := info.TotalLength() - index*info.PieceLength; last > 0 {
+			return last
+		}
+	}
+	return info.PieceLength
+}
+
+// Locate maps length bytes of the torrent's data starting at offset onto
+// the files holding them. Empty files never appear in the result.
+func (info Info) Locate(offset, length int) []Segment {
+	var result []Segment
+	start := 0
+	for i, f := range info.FileList() {
+		end := start + f.Length
+		if length > 0 && offset < end && f.Length > 0 {
+			n := min(end-offset, length)
+			result = append(result, Segment{File: i, Offset: offset - start, Length: n})
+			offset += n
+			length -= n
+		}
+		start = end
+	}
+	return result
+}
+
+func (info Info) validateFiles() error {
+	if info.Length < 0 {
+		return fmt.Errorf("%w: negative length", ErrInvalid)
+	}
+	if !info.IsMultiFile() {
+		if info.Length == 0 {
+			return fmt.Errorf("%w: missing length and files", ErrInvalid)
+		}
+		return validName(info.Name)
+	}
+
+	if info.Length != 0 {
+		return fmt.Errorf("%w: both length and files are set", ErrInvalid)
+	}
+	if err := validName(info.Name); err != nil {
+		return err
+	}
+	for _, f := range info.Files {
+		if f.Length < 0 {
+			return fmt.Errorf("%w: negative length for %q", ErrInvalid, f.Path)
+		}
+		if len(f.Path) == 0 {
+			return fmt.Errorf("%w: file with empty path", ErrInvalid)
+		}
+		for _, component := range f.Path {
+			if err := validName(component); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validName rejects path components that would escape the download
+// directory.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("%w: unsafe path component %q", ErrInvalid, name)
+	}
+	return nil
+}
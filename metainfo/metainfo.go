@@ -0,0 +1,135 @@
+// Package metainfo models the contents of a .torrent file.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"torrent-client/bencode"
+)
+
+// ErrInvalid is returned for torrent files that decode correctly but do not
+// describe a usable torrent.
+var ErrInvalid = errors.New("metainfo: invalid torrent")
+
+// MetaInfo is the decoded contents of a .torrent file.
+type MetaInfo struct {
+	Announce     string
+	AnnounceList [][]string `bencoded:"announce-list,omitempty"`
+	CreatedBy    string     `bencoded:"created by,omitempty"`
+	Info         Info
+}
+
+// Info is the info dictionary of a torrent, describing its content. A
+// single-file torrent sets Length; a multi-file torrent sets Files instead
+// and uses Name as the directory holding them.
+type Info struct {
+	Length      int    `bencoded:",omitempty"`
+	Files       []File `bencoded:",omitempty"`
+	Name        string
+	PieceLength int
+	Pieces      []byte
+	Md5sum      string `bencoded:",omitempty"`
+
+	// raw holds the dictionary as it appeared in the file, including any
+	// keys not modelled above.
+	raw bencode.RawMessage
+}
+
+// info is Info without its custom encoding.
+type info Info
+
+// UnmarshalBencode decodes the info dictionary, keeping a copy of its raw
+// encoding.
+func (i *Info) UnmarshalBencode(data []byte) error {
+	if err := bencode.Unmarshal(data, (*info)(i)); err != nil {
+		return err
+	}
+	return i.raw.UnmarshalBencode(data)
+}
+
+// MarshalBencode returns the info dictionary exactly as it was decoded, or
+// encodes the fields if it was built in memory.
+func (i Info) MarshalBencode() ([]byte, error) {
+	if i.raw != nil {
+		return i.raw, nil
+	}
+	return bencode.Marshal(info(i))
+}
+
+// Hash returns the SHA-1 hash of the encoded info dictionary, which
+// identifies the torrent to trackers and peers.
+func (i Info) Hash() [20]byte {
+	data, err := i.MarshalBencode()
+	if err != nil {
+		return [20]byte{}
+	}
+	return sha1.Sum(data)
+}
+
+// Load reads and decodes the torrent file stored at path.
+func Load(path string) (MetaInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MetaInfo{}, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes the contents of a torrent file.
+func Parse(data []byte) (MetaInfo, error) {
+	var result MetaInfo
+	if err := bencode.Unmarshal(data, &result); err != nil {
+		return MetaInfo{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if result.Info.raw == nil {
+		return MetaInfo{}, fmt.Errorf("%w: missing info dictionary", ErrInvalid)
+	}
+
+	if err := result.Info.validate(); err != nil {
+		return MetaInfo{}, err
+	}
+	return result, nil
+}
+
+// Trackers returns the torrent's tracker URLs grouped into tiers, taken
+// from announce-list (BEP 12) if present and from announce otherwise.
+func (m MetaInfo) Trackers() [][]string {
+	var tiers [][]string
+	for _, tier := range m.AnnounceList {
+		if len(tier) > 0 {
+			tiers = append(tiers, slices.Clone(tier))
+		}
+	}
+	if len(tiers) == 0 && m.Announce != "" {
+		tiers = [][]string{{m.Announce}}
+	}
+	return tiers
+}
+
+// InfoHash returns the SHA-1 hash of the raw info dictionary, which
+// identifies the torrent to trackers and peers.
+func (m MetaInfo) InfoHash() [20]byte {
+	return m.Info.Hash()
+}
+
+func (info Info) validate() error {
+	if info.PieceLength <= 0 {
+		return fmt.Errorf("%w: piece length %d", ErrInvalid, info.PieceLength)
+	}
+	if len(info.Pieces) == 0 || len(info.Pieces)%20 != 0 {
+		return fmt.Errorf("%w: pieces length %d is not a multiple of 20", ErrInvalid, len(info.Pieces))
+	}
+	if err := info.validateFiles(); err != nil {
+		return err
+	}
+
+	total := info.TotalLength()
+	if expected := (total + info.PieceLength - 1) / info.PieceLength; expected != info.NumPieces() {
+		return fmt.Errorf("%w: %d pieces for %d bytes", ErrInvalid, info.NumPieces(), total)
+	}
+	return nil
+}
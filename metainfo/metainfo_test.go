@@ -0,0 +1,100 @@
+package metainfo
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"torrent-client/bencode"
+)
+
+func TestInfoHash(t *testing.T) {
+	m, err := Load("../bencode/tests/sample.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := m.InfoHash()
+	if hex.EncodeToString(hash[:]) != "d69f91e6b2ae4c542468d1073a71d4ea13879a7f" {
+		t.Errorf("%x", hash)
+	}
+}
+
+func TestInfoHashKeepsUnknownKeys(t *testing.T) {
+	a, err := Parse([]byte("d4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse([]byte("d4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaa7:private1:1ee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.InfoHash() == b.InfoHash() {
+		t.Error("unknown info keys must change the info hash")
+	}
+}
+
+func TestMarshalKeepsInfo(t *testing.T) {
+	m, err := Parse([]byte("d8:announce3:url4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaa7:privatei1eee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := bencode.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.InfoHash() != m.InfoHash() || again.Info.Name != "a" {
+		t.Error(string(data))
+	}
+}
+
+func TestMultiFile(t *testing.T) {
+	m, err := Parse([]byte("d4:infod5:filesld6:lengthi3e4:pathl1:aeed6:lengthi0e4:pathl5:empty" +
+		"eed6:lengthi6e6:md5sum32:0123456789abcdef0123456789abcdef4:pathl3:sub1:beee" +
+		"4:name3:dir12:piece lengthi5e6:pieces40:aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Info.TotalLength() != 9 || m.Info.NumPieces() != 2 || m.Info.PieceSize(1) != 4 {
+		t.Error(m.Info.TotalLength(), m.Info.NumPieces(), m.Info.PieceSize(1))
+	}
+
+	files := m.Info.FileList()
+	if files[2].FilePath() != "dir/sub/b" || files[2].Md5sum == "" {
+		t.Error(files)
+	}
+
+	expected := []Segment{{File: 0, Offset: 2, Length: 1}, {File: 2, Offset: 0, Length: 3}}
+	if segments := m.Info.Locate(2, 4); !reflect.DeepEqual(expected, segments) {
+		t.Error(segments)
+	}
+}
+
+func TestInvalidFiles(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{"escaping path", "d4:infod5:filesld6:lengthi1e4:pathl2:..1:aeee4:name1:d12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"},
+		{"separator in path", "d4:infod5:filesld6:lengthi1e4:pathl3:a/beee4:name1:d12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"},
+		{"length and files", "d4:infod5:filesld6:lengthi1e4:pathl1:aeee6:lengthi1e4:name1:d12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"},
+		{"piece count", "d4:infod6:lengthi3e4:name1:a12:piece lengthi1e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Parse([]byte(c.input)); !errors.Is(err, ErrInvalid) {
+				t.Error(err)
+			}
+		})
+	}
+}
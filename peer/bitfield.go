@@ -0,0 +1,45 @@
+package peer
+
+// Bitfield records which pieces a peer has, the high bit of the first byte
+// being piece 0.
+type Bitfield []byte
+
+// NewBitfield returns an empty Bitfield for n pieces.
+func NewBitfield(n int) Bitfield {
+	return make(Bitfield, (n+7)/8)
+}
+
+// Has reports whether piece i is set.
+func (b Bitfield) Has(i int) bool {
+	if i < 0 || i/8 >= len(b) {
+		return false
+	}
+	return b[i/8]&(0x80>>(i%8)) != 0
+}
+
+// Set marks piece i as present.
+func (b Bitfield) Set(i int) {
+	if i >= 0 && i/8 < len(b) {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+}
+
+// Count returns the number of pieces set.
+func (b Bitfield) Count() int {
+	n := 0
+	for _, i := range b {
+		for ; i != 0; i &= i - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// valid reports whether b has the right length for n pieces and no spare
+// bits set past the last piece.
+func (b Bitfield) valid(n int) bool {
+	if len(b) != (n+7)/8 {
+		return false
+	}
+	return n%8 == 0 || b[len(b)-1]&(0xff>>(n%8)) == 0
+}
@@ -0,0 +1,481 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"torrent-client/metainfo"
+)
+
+// BLOCK_SIZE is the size of the blocks requested from peers.
+const BLOCK_SIZE uint32 = 16 * 1024
+
+// Default timings of a Conn.
+const (
+	// DefaultKeepAlive is how long we may go without sending anything
+	// before sending a keep-alive.
+	DefaultKeepAlive = 90 * time.Second
+	// DefaultIdleTimeout is how long we wait for the peer to send anything
+	// before dropping the connection. Peers send keep-alives every two
+	// minutes.
+	DefaultIdleTimeout = 3 * time.Minute
+)
+
+// handshakeTimeout bounds the handshake exchange when dialing.
+const handshakeTimeout = 10 * time.Second
+
+// Conn is a connection to a single peer. Messages are read by a single
+// goroutine calling ReadPeerMsg, which keeps the connection's State up to
+// date; Send may be called from any goroutine.
+type Conn struct {
+	net.Conn
+	Status   Status
+	Address  netip.AddrPort
+	Frames   *FrameReader
+	InfoHash [20]byte
+	PeerID   [20]byte
+	Torrent  metainfo.Info
+
+	// Remote is the handshake the peer sent, giving its peer ID and the
+	// capabilities it supports.
+	Remote HandshakeMsg
+
+	// KeepAlive and IdleTimeout default to DefaultKeepAlive and
+	// DefaultIdleTimeout; zero disables them.
+	KeepAlive   time.Duration
+	IdleTimeout time.Duration
+
+	// MinQueueDepth and MaxQueueDepth bound the number of block requests
+	// kept outstanding; they default to DefaultMinQueueDepth and
+	// DefaultMaxQueueDepth.
+	MinQueueDepth int
+	MaxQueueDepth int
+
+	mu       sync.Mutex // guards the fields below
+	state    State
+	pieces   Bitfield
+	received int // messages read since the handshake, keep-alives aside
+	requests map[Block]time.Time
+	pipe     pipeline
+
+	writeMu  sync.Mutex // guards writes and lastSend
+	lastSend time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// State is the choke and interest state of a connection in both
+// directions. Connections start out choked and not interested both ways.
+type State struct {
+	AmChoking      bool // we refuse to upload to the peer
+	AmInterested   bool // we want pieces the peer has
+	PeerChoking    bool // the peer refuses to upload to us
+	PeerInterested bool // the peer wants pieces we have
+}
+
+// Status is the state of a peer connection.
+type Status uint8
+
+const (
+	Idle Status = iota
+	Active
+	Done
+	Disconnected
+)
+
+// Dial connects to the peer at address, over IPv4 or IPv6 as its address
+// requires, to exchange the torrent identified by infoHash and described by
+// info, and performs the handshake introducing ourselves as peerID. Once
+// connected, keep-alives are sent until the Conn is closed.
+func Dial(address netip.AddrPort, infoHash, peerID [20]byte, info metainfo.Info) (*Conn, error) {
+	address = netip.AddrPortFrom(address.Addr().Unmap(), address.Port())
+	network := "tcp4"
+	if address.Addr().Is6() {
+		network = "tcp6"
+	}
+	conn, err := net.DialTimeout(network, address.String(), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newConn(conn, address, infoHash, peerID, info)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if _, err := result.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	go result.keepAlive()
+	return result, nil
+}
+
+func newConn(conn net.Conn, address netip.AddrPort, infoHash, peerID [20]byte, info metainfo.Info) *Conn {
+	return &Conn{
+		Conn:        conn,
+		Status:      Idle,
+		Address:     address,
+		Frames:      NewFrameReader(conn),
+		InfoHash:    infoHash,
+		PeerID:      peerID,
+		Torrent:     info,
+		KeepAlive:   DefaultKeepAlive,
+		IdleTimeout: DefaultIdleTimeout,
+
+		MinQueueDepth: DefaultMinQueueDepth,
+		MaxQueueDepth: DefaultMaxQueueDepth,
+
+		state:    State{AmChoking: true, PeerChoking: true},
+		pieces:   NewBitfield(info.NumPieces()),
+		requests: make(map[Block]time.Time),
+		lastSend: time.Now(),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Handshake sends our handshake and reads the peer's, checking that it is
+// for the same torrent and not from ourselves. It returns the remote peer
+// ID; the whole handshake is kept in Remote.
+func (conn *Conn) Handshake() ([20]byte, error) {
+	var peerID [20]byte
+	handshakeMsg := NewHandshakeMsg(conn.InfoHash, conn.PeerID)
+	if _, err := conn.Write(handshakeMsg.ToBytes()); err != nil {
+		return peerID, err
+	}
+
+	buf := make([]byte, HandshakeSize)
+	if _, err := io.ReadFull(conn.Frames, buf); err != nil {
+		return peerID, err
+	}
+	remote, err := ParseHandshake(buf)
+	if err != nil {
+		return peerID, conn.wrap(err)
+	}
+	if !bytes.Equal(remote.InfoHash, conn.InfoHash[:]) {
+		return peerID, conn.protocolError("handshake for info hash %x", remote.InfoHash)
+	}
+	if bytes.Equal(remote.PeerId, conn.PeerID[:]) {
+		return peerID, conn.protocolError("connected to ourselves")
+	}
+
+	conn.Remote = remote
+	copy(peerID[:], remote.PeerId)
+	return peerID, nil
+}
+
+func (conn *Conn) protocolError(format string, args ...any) error {
+	return &ProtocolError{Addr: conn.Address.String(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// wrap turns an invalid message error into a ProtocolError naming the peer.
+func (conn *Conn) wrap(err error) error {
+	var invalid *MessageError
+	if errors.As(err, &invalid) {
+		return &ProtocolError{Addr: conn.Address.String(), Msg: invalid.Msg, Err: err}
+	}
+	return err
+}
+
+// State returns the current choke and interest state.
+func (conn *Conn) State() State {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.state
+}
+
+// HasPiece reports whether the peer has announced the piece at index.
+func (conn *Conn) HasPiece(index int) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.pieces.Has(index)
+}
+
+// Send writes msg to the peer, recording the choke or interest change it
+// makes.
+func (conn *Conn) Send(msg Message) error {
+	conn.mu.Lock()
+	switch msg.(type) {
+	case ChokeMsg:
+		conn.state.AmChoking = true
+	case UnchokeMsg:
+		conn.state.AmChoking = false
+	case InterestedMsg:
+		conn.state.AmInterested = true
+	case NotInterestedMsg:
+		conn.state.AmInterested = false
+	}
+	conn.mu.Unlock()
+
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	if _, err := conn.Write(ToBytes(msg)); err != nil {
+		return err
+	}
+	conn.lastSend = time.Now()
+	return nil
+}
+
+// Close stops the keep-alives and closes the connection.
+func (conn *Conn) Close() error {
+	conn.closeOnce.Do(func() { close(conn.closed) })
+	return conn.Conn.Close()
+}
+
+// keepAlive sends a keep-alive whenever nothing was sent for KeepAlive,
+// until the connection is closed or a write fails.
+func (conn *Conn) keepAlive() {
+	if conn.KeepAlive <= 0 {
+		return
+	}
+	for {
+		conn.writeMu.Lock()
+		wait := time.Until(conn.lastSend.Add(conn.KeepAlive))
+		conn.writeMu.Unlock()
+
+		if wait <= 0 {
+			if err := conn.Send(KeepAliveMsg{}); err != nil {
+				return
+			}
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-conn.closed:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// handle updates the connection state for a message from the peer.
+func (conn *Conn) handle(msg Message) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	first := conn.received == 0
+	conn.received++
+
+	switch m := msg.(type) {
+	case ChokeMsg:
+		// The peer drops our requests when it chokes us.
+		conn.state.PeerChoking = true
+		clear(conn.requests)
+	case UnchokeMsg:
+		conn.state.PeerChoking = false
+	case InterestedMsg:
+		conn.state.PeerInterested = true
+	case NotInterestedMsg:
+		conn.state.PeerInterested = false
+	case HaveMsg:
+		if m.PieceIndex < 0 || int(m.PieceIndex) >= conn.Torrent.NumPieces() {
+			return conn.protocolError("have for piece %d of %d", m.PieceIndex, conn.Torrent.NumPieces())
+		}
+		conn.pieces.Set(int(m.PieceIndex))
+	case BitfieldMsg:
+		if !first {
+			return conn.protocolError("bitfield after the first message")
+		}
+		if !Bitfield(m.Bitfield).valid(conn.Torrent.NumPieces()) {
+			return conn.protocolError("bitfield of %d bytes for %d pieces", len(m.Bitfield), conn.Torrent.NumPieces())
+		}
+		copy(conn.pieces, m.Bitfield)
+	case PieceMsg:
+		block := Block{Index: m.Index, Begin: m.Begin, Length: uint32(len(m.Block))}
+		if sent, ok := conn.requests[block]; ok {
+			delete(conn.requests, block)
+			conn.pipe.received(len(m.Block), sent, time.Now())
+		}
+	case RequestMsg, CancelMsg:
+		// We do not upload yet, so the peer stays choked: its requests
+		// are dropped and there is nothing to cancel.
+	}
+	return nil
+}
+
+// Block identifies a part of a piece to request.
+type Block struct {
+	Index  uint32
+	Begin  uint32
+	Length uint32
+}
+
+// QueueDepth returns the number of block requests to keep outstanding,
+// adapted to the peer's download rate and latency.
+func (conn *Conn) QueueDepth() int {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.pipe.depth(conn.MinQueueDepth, conn.MaxQueueDepth)
+}
+
+// Outstanding returns the number of requests the peer has yet to answer.
+func (conn *Conn) Outstanding() int {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return len(conn.requests)
+}
+
+// Request asks the peer for block. The request stays outstanding until
+// ReadPeerMsg reads the block, or a choke discards it.
+func (conn *Conn) Request(block Block) error {
+	conn.mu.Lock()
+	conn.requests[block] = time.Now()
+	conn.mu.Unlock()
+	return conn.Send(RequestMsg{Index: block.Index, Begin: block.Begin, Length: block.Length})
+}
+
+// Cancel withdraws the request for block, which was received from another
+// peer. The peer may still send it if the cancel crosses the block.
+func (conn *Conn) Cancel(block Block) error {
+	conn.mu.Lock()
+	_, ok := conn.requests[block]
+	delete(conn.requests, block)
+	conn.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return conn.Send(CancelMsg{Index: block.Index, Begin: block.Begin, Length: block.Length})
+}
+
+// Block states while downloading a piece.
+const (
+	blockWanted = iota
+	blockRequested
+	blockReceived
+)
+
+// DownloadPiece downloads the piece at index, telling the peer we are
+// interested and waiting for it to unchoke us, and checks the result
+// against the piece's SHA-1 hash. A piece that does not match returns a
+// *HashError.
+//
+// Up to QueueDepth blocks are requested at a time and may arrive in any
+// order. A choke discards the outstanding requests, which are sent again
+// after the next unchoke.
+func (conn *Conn) DownloadPiece(index int) ([]byte, error) {
+	if !conn.State().AmInterested {
+		if err := conn.Send(InterestedMsg{}); err != nil {
+			return nil, err
+		}
+	}
+
+	size := conn.Torrent.PieceSize(index)
+	piece := make([]byte, size)
+	blocks := make([]int, (size+int(BLOCK_SIZE)-1)/int(BLOCK_SIZE))
+	left := len(blocks)
+
+	for left > 0 {
+		if !conn.State().PeerChoking {
+			for i := 0; i < len(blocks) && conn.Outstanding() < conn.QueueDepth(); i++ {
+				if blocks[i] != blockWanted {
+					continue
+				}
+				begin := i * int(BLOCK_SIZE)
+				length := min(int(BLOCK_SIZE), size-begin)
+				if err := conn.Request(Block{Index: uint32(index), Begin: uint32(begin), Length: uint32(length)}); err != nil {
+					return nil, err
+				}
+				blocks[i] = blockRequested
+			}
+		}
+
+		msg, err := conn.ReadPeerMsg()
+		if err != nil {
+			return nil, err
+		}
+		switch m := msg.(type) {
+		case ChokeMsg:
+			for i := range blocks {
+				if blocks[i] == blockRequested {
+					blocks[i] = blockWanted
+				}
+			}
+		case PieceMsg:
+			if int(m.Index) != index || m.Begin%BLOCK_SIZE != 0 || int(m.Begin) >= size {
+				continue
+			}
+			i := int(m.Begin / BLOCK_SIZE)
+			if blocks[i] == blockReceived {
+				continue
+			}
+			if length := min(int(BLOCK_SIZE), size-int(m.Begin)); len(m.Block) != length {
+				return nil, conn.protocolError("block of %d bytes, requested %d", len(m.Block), length)
+			}
+			copy(piece[m.Begin:], m.Block)
+			blocks[i] = blockReceived
+			left--
+		}
+	}
+
+	if sha1.Sum(piece) != conn.Torrent.PieceHash(index) {
+		return nil, &HashError{Addr: conn.Address.String(), Index: index}
+	}
+	return piece, nil
+}
+
+// ReadPeerMsg reads the next message sent by the peer and updates the
+// connection state accordingly. Keep-alives are skipped; if the peer sends
+// nothing at all for IdleTimeout, an error matching ErrIdle is returned.
+func (conn *Conn) ReadPeerMsg() (Message, error) {
+	for {
+		if conn.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(conn.IdleTimeout))
+		}
+		msg, err := conn.Frames.ReadMessage()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("peer %s: %w", conn.Address, ErrIdle)
+		} else if err != nil {
+			return nil, conn.wrap(err)
+		}
+		if _, ok := msg.(KeepAliveMsg); ok {
+			continue
+		}
+		if err := conn.handle(msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+}
+
+// DownloadBlock requests length bytes of the piece at index, starting at
+// begin, and waits for them to arrive, first waiting to be unchoked. Since
+// a choke discards outstanding requests, the block is requested again
+// after the next unchoke.
+func (conn *Conn) DownloadBlock(index, begin, length uint32) ([]byte, error) {
+	requested := false
+	for {
+		if !requested && !conn.State().PeerChoking {
+			if err := conn.Request(Block{Index: index, Begin: begin, Length: length}); err != nil {
+				return nil, err
+			}
+			requested = true
+		}
+
+		response, err := conn.ReadPeerMsg()
+		if err != nil {
+			return nil, err
+		}
+		switch m := response.(type) {
+		case ChokeMsg:
+			requested = false
+		case PieceMsg:
+			if m.Index != index || m.Begin != begin {
+				continue
+			}
+			if len(m.Block) != int(length) {
+				return nil, conn.protocolError("block of %d bytes, requested %d", len(m.Block), length)
+			}
+			return bytes.Clone(m.Block), nil
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"torrent-client/metainfo"
+)
+
+// testInfo describes a torrent of ten one-block pieces.
+var testInfo = metainfo.Info{
+	Name:        "test",
+	Length:      10 * int(BLOCK_SIZE),
+	PieceLength: int(BLOCK_SIZE),
+	Pieces:      make([]byte, 10*20),
+}
+
+// pipeConn returns a Conn for the torrent infoHash, described by testInfo,
+// whose other end is returned as remote.
+func pipeConn(t *testing.T, infoHash [20]byte) (conn *Conn, remote net.Conn) {
+	local, remote := net.Pipe()
+	conn = newConn(local, netip.MustParseAddrPort("127.0.0.1:6881"), infoHash, [20]byte{'u', 's'}, testInfo)
+	t.Cleanup(func() {
+		conn.Close()
+		remote.Close()
+	})
+	return conn, remote
+}
+
+// script plays the remote end of a connection, sending messages and
+// checking the ones it expects. Messages are sent in order from a separate
+// goroutine, so that neither end blocks the other on the unbuffered pipe.
+type script struct {
+	t      *testing.T
+	frames *FrameReader
+	out    chan Message
+	done   chan struct{}
+}
+
+func newScript(t *testing.T, remote net.Conn) *script {
+	s := &script{t: t, frames: NewFrameReader(remote), out: make(chan Message, 64), done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-s.done:
+				return
+			case msg := <-s.out:
+				if _, err := remote.Write(ToBytes(msg)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	t.Cleanup(func() { close(s.done) })
+	return s
+}
+
+func (s *script) send(msgs ...Message) {
+	for _, msg := range msgs {
+		select {
+		case <-s.done:
+			return
+		case s.out <- msg:
+		}
+	}
+}
+
+func (s *script) expect(expected Message) {
+	msg, err := s.frames.ReadMessage()
+	if err != nil || !reflect.DeepEqual(expected, msg) {
+		s.t.Errorf("remote got %#v, %v, expected %#v", msg, err, expected)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	infoHash := [20]byte{1, 2, 3}
+	them := [20]byte{'t', 'h', 'e', 'm'}
+
+	// reply performs a handshake with a peer answering with handshake.
+	reply := func(t *testing.T, handshake []byte) error {
+		conn, remote := pipeConn(t, infoHash)
+		go func() {
+			io.ReadFull(remote, make([]byte, HandshakeSize))
+			remote.Write(handshake)
+		}()
+		_, err := conn.Handshake()
+		return err
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		msg := NewHandshakeMsg(infoHash, them)
+		msg.Set(Fast)
+		msg.Set(Extension)
+
+		conn, remote := pipeConn(t, infoHash)
+		go func() {
+			buf := make([]byte, HandshakeSize)
+			io.ReadFull(remote, buf)
+			if _, err := ParseHandshake(buf); err != nil {
+				t.Error(err)
+			}
+			remote.Write(msg.ToBytes())
+		}()
+
+		peerID, err := conn.Handshake()
+		if err != nil || peerID != them {
+			t.Fatal(peerID, err)
+		}
+		if !conn.Remote.Has(Fast) || !conn.Remote.Has(Extension) || conn.Remote.Has(DHT) {
+			t.Error(conn.Remote.Reserved)
+		}
+	})
+
+	t.Run("wrong info hash", func(t *testing.T) {
+		msg := NewHandshakeMsg([20]byte{9}, them)
+		if err := reply(t, msg.ToBytes()); !errors.Is(err, ErrProtocol) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("ourselves", func(t *testing.T) {
+		msg := NewHandshakeMsg(infoHash, [20]byte{'u', 's'})
+		if err := reply(t, msg.ToBytes()); !errors.Is(err, ErrProtocol) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("wrong protocol", func(t *testing.T) {
+		encoded := NewHandshakeMsg(infoHash, them).ToBytes()
+		copy(encoded[1:], "BitTorrent protocoX")
+		var invalid *MessageError
+		if err := reply(t, encoded); !errors.As(err, &invalid) || !errors.Is(err, ErrProtocol) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		encoded := NewHandshakeMsg(infoHash, them).ToBytes()
+		conn, remote := pipeConn(t, infoHash)
+		go func() {
+			io.ReadFull(remote, make([]byte, HandshakeSize))
+			remote.Write(encoded[:40])
+			remote.Close()
+		}()
+		if _, err := conn.Handshake(); err != io.ErrUnexpectedEOF {
+			t.Error(err)
+		}
+	})
+}
+
+func TestStateMachine(t *testing.T) {
+	conn, remote := pipeConn(t, [20]byte{})
+	go newScript(t, remote).send(
+		BitfieldMsg{Bitfield: []byte{0x80, 0x00}},
+		KeepAliveMsg{},
+		HaveMsg{PieceIndex: 9},
+		InterestedMsg{},
+		UnchokeMsg{},
+		CancelMsg{Index: 1},
+		ChokeMsg{},
+		HaveMsg{PieceIndex: 10},
+	)
+
+	expected := []State{
+		{AmChoking: true, PeerChoking: true},
+		{AmChoking: true, PeerChoking: true},
+		{AmChoking: true, PeerChoking: true, PeerInterested: true},
+		{AmChoking: true, PeerInterested: true},
+		{AmChoking: true, PeerInterested: true},
+		{AmChoking: true, PeerChoking: true, PeerInterested: true},
+	}
+	for n, e := range expected {
+		if _, err := conn.ReadPeerMsg(); err != nil {
+			t.Fatal(err)
+		}
+		if conn.State() != e {
+			t.Error("after message", n, "state is", conn.State(), "expected", e)
+		}
+	}
+	if !conn.HasPiece(0) || conn.HasPiece(1) || !conn.HasPiece(9) {
+		t.Error(conn.pieces)
+	}
+
+	if _, err := conn.ReadPeerMsg(); !errors.Is(err, ErrProtocol) {
+		t.Error("have for a piece past the end should fail:", err)
+	}
+}
+
+func TestBitfieldValidation(t *testing.T) {
+	for name, msgs := range map[string][]Message{
+		"late":        {HaveMsg{}, BitfieldMsg{Bitfield: []byte{0, 0}}},
+		"short":       {BitfieldMsg{Bitfield: []byte{0}}},
+		"spare bits":  {BitfieldMsg{Bitfield: []byte{0, 0x20}}},
+		"extra bytes": {BitfieldMsg{Bitfield: []byte{0, 0, 0}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, remote := pipeConn(t, [20]byte{})
+			go newScript(t, remote).send(msgs...)
+
+			var err error
+			for range msgs {
+				if _, err = conn.ReadPeerMsg(); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, ErrProtocol) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDownloadBlockChokedMidRequest(t *testing.T) {
+	conn, remote := pipeConn(t, [20]byte{})
+	block := make([]byte, BLOCK_SIZE)
+	block[0] = 42
+	request := RequestMsg{Index: 3, Begin: 0, Length: BLOCK_SIZE}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s := newScript(t, remote)
+		s.send(UnchokeMsg{})
+		s.expect(request)
+		s.send(ChokeMsg{}, UnchokeMsg{})
+		s.expect(request)
+		s.send(PieceMsg{Index: 3, Begin: 0, Block: block})
+	}()
+
+	received, err := conn.DownloadBlock(3, 0, BLOCK_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received[0] != 42 {
+		t.Error(received[:4])
+	}
+	<-done
+}
+
+func TestCancel(t *testing.T) {
+	conn, remote := pipeConn(t, [20]byte{})
+	block := Block{Index: 2, Begin: BLOCK_SIZE, Length: 100}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s := newScript(t, remote)
+		s.expect(RequestMsg{Index: 2, Begin: BLOCK_SIZE, Length: 100})
+		s.expect(CancelMsg{Index: 2, Begin: BLOCK_SIZE, Length: 100})
+		s.expect(KeepAliveMsg{})
+	}()
+
+	if err := conn.Request(block); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := conn.Cancel(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conn.Outstanding() != 0 {
+		t.Error(conn.Outstanding())
+	}
+	// Only one cancel is sent.
+	conn.Send(KeepAliveMsg{})
+	<-done
+}
+
+// newTestTorrent returns the metainfo of data split into pieces of
+// pieceLength bytes.
+func newTestTorrent(data []byte, pieceLength int) metainfo.Info {
+	info := metainfo.Info{Name: "test", Length: len(data), PieceLength: pieceLength}
+	for i := 0; i < len(data); i += pieceLength {
+		hash := sha1.Sum(data[i:min(i+pieceLength, len(data))])
+		info.Pieces = append(info.Pieces, hash[:]...)
+	}
+	return info
+}
+
+// serve answers every request with the requested part of data, after
+// unchoking, until the connection is closed.
+func (s *script) serve(data []byte, pieceLength int) {
+	s.send(UnchokeMsg{})
+	for {
+		msg, err := s.frames.ReadMessage()
+		if err != nil {
+			return
+		}
+		if m, ok := msg.(RequestMsg); ok {
+			start := int(m.Index)*pieceLength + int(m.Begin)
+			s.send(PieceMsg{Index: m.Index, Begin: m.Begin, Block: data[start : start+int(m.Length)]})
+		}
+	}
+}
+
+func TestDownloadPiece(t *testing.T) {
+	pieceLength := 2 * int(BLOCK_SIZE)
+	data := make([]byte, 2*pieceLength+int(BLOCK_SIZE)+100)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	info := newTestTorrent(data, pieceLength)
+
+	conn, remote := pipeConn(t, [20]byte{})
+	conn.Torrent = info
+	go newScript(t, remote).serve(data, pieceLength)
+
+	for index := range info.NumPieces() {
+		piece, err := conn.DownloadPiece(index)
+		if err != nil {
+			t.Fatal(index, err)
+		}
+		if !bytes.Equal(data[index*pieceLength:min((index+1)*pieceLength, len(data))], piece) {
+			t.Error("piece", index, "differs")
+		}
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[0]++
+	conn, remote = pipeConn(t, [20]byte{})
+	conn.Torrent = info
+	go newScript(t, remote).serve(corrupt, pieceLength)
+	if _, err := conn.DownloadPiece(0); !errors.Is(err, ErrHashMismatch) {
+		t.Error(err)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	conn, remote := pipeConn(t, [20]byte{})
+	conn.KeepAlive = 10 * time.Millisecond
+	go conn.keepAlive()
+
+	s := newScript(t, remote)
+	s.expect(KeepAliveMsg{})
+	s.expect(KeepAliveMsg{})
+}
+
+func TestIdleTimeout(t *testing.T) {
+	conn, _ := pipeConn(t, [20]byte{})
+	conn.IdleTimeout = 20 * time.Millisecond
+
+	if _, err := conn.ReadPeerMsg(); !errors.Is(err, ErrIdle) {
+		t.Error(err)
+	}
+}
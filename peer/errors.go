@@ -0,0 +1,66 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrProtocol is matched by every error caused by a peer violating the
+// wire protocol.
+var ErrProtocol = errors.New("peer: protocol violation")
+
+// ErrIdle is matched by the error returned when a peer stays silent for
+// longer than its connection's IdleTimeout.
+var ErrIdle = errors.New("peer: connection idle")
+
+// ErrHashMismatch is matched by the error returned when a downloaded piece
+// does not match its hash.
+var ErrHashMismatch = errors.New("peer: piece hash mismatch")
+
+// HashError reports that the piece at Index, downloaded from the peer at
+// Addr, does not match its hash.
+type HashError struct {
+	Addr  string
+	Index int
+}
+
+func (e *HashError) Error() string {
+	return fmt.Sprintf("peer %s: piece %d does not match its hash", e.Addr, e.Index)
+}
+
+func (e *HashError) Is(target error) bool {
+	return target == ErrHashMismatch
+}
+
+// ProtocolError describes a protocol violation by the peer at Addr. Err,
+// if set, is the underlying error, such as a *MessageError.
+type ProtocolError struct {
+	Addr string
+	Msg  string
+	Err  error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("peer %s: %s", e.Addr, e.Msg)
+}
+
+func (e *ProtocolError) Is(target error) bool {
+	return target == ErrProtocol
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// MessageError describes a frame that is not a valid message.
+type MessageError struct {
+	Msg string
+}
+
+func (e *MessageError) Error() string {
+	return "peer: invalid message: " + e.Msg
+}
+
+func (e *MessageError) Is(target error) bool {
+	return target == ErrProtocol
+}
@@ -0,0 +1,76 @@
+package peer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxFrameSize is the largest frame a FrameReader accepts by
+// default. It leaves room for a 128 KiB block, more than any client
+// requests, and for the bitfield of a torrent with millions of pieces.
+const DefaultMaxFrameSize = 1 << 20
+
+// FrameReader splits a peer wire stream into length-prefixed frames,
+// however the underlying reads happen to be sized.
+type FrameReader struct {
+	// MaxFrameSize is the largest length prefix accepted.
+	MaxFrameSize int
+
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewFrameReader returns a FrameReader reading from r through a buffer.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{
+		MaxFrameSize: DefaultMaxFrameSize,
+		r:            bufio.NewReader(r),
+	}
+}
+
+// Read reads directly from the buffered stream, for the parts of the
+// protocol that are not framed, such as the handshake.
+func (f *FrameReader) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+// ReadFrame reads the next frame, length prefix included. The frame is only
+// valid until the next call. A stream that ends inside a frame returns
+// io.ErrUnexpectedEOF.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(f.r, prefix[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	if uint64(length) > uint64(f.MaxFrameSize) {
+		return nil, &MessageError{Msg: fmt.Sprintf("frame of %d bytes exceeds limit of %d", length, f.MaxFrameSize)}
+	}
+
+	size := 4 + int(length)
+	if cap(f.buf) < size {
+		f.buf = make([]byte, size)
+	}
+	frame := f.buf[:size]
+	copy(frame, prefix[:])
+	if _, err := io.ReadFull(f.r, frame[4:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+// ReadMessage reads and parses the next frame. Keep-alives are returned as
+// KeepAliveMsg. Byte slices in the message are only valid until the next
+// call.
+func (f *FrameReader) ReadMessage() (Message, error) {
+	frame, err := f.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(frame)
+}
@@ -0,0 +1,77 @@
+package peer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFromBytesErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		input []byte
+	}{
+		{"no prefix", []byte{0, 0}},
+		{"truncated frame", []byte{0, 0, 0, 5, byte(MsgHave), 0, 0}},
+		{"truncated have", []byte{0, 0, 0, 3, byte(MsgHave), 0, 0}},
+		{"truncated request", []byte{0, 0, 0, 5, byte(MsgRequest), 0, 0, 0, 1}},
+		{"truncated piece", []byte{0, 0, 0, 5, byte(MsgPiece), 0, 0, 0, 1}},
+		{"truncated port", []byte{0, 0, 0, 2, byte(MsgPort), 1}},
+		{"choke with payload", []byte{0, 0, 0, 2, byte(MsgChoke), 1}},
+		{"unknown id", []byte{0, 0, 0, 1, 42}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg, err := FromBytes(c.input)
+			var invalid *MessageError
+			if !errors.As(err, &invalid) || !errors.Is(err, ErrProtocol) {
+				t.Error(msg, err)
+			}
+		})
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	stream := []byte{
+		0, 0, 0, 0, // keep-alive
+		0, 0, 0, 1, byte(MsgUnchoke),
+		0, 0, 0, 5, byte(MsgHave), 0, 0, 0, 7,
+		0, 0, 0, 11, byte(MsgPiece), 0, 0, 0, 1, 0, 0, 0, 2, 'a', 'b',
+	}
+	expected := []Message{KeepAliveMsg{}, UnchokeMsg{}, HaveMsg{PieceIndex: 7}, PieceMsg{Index: 1, Begin: 2, Block: []byte("ab")}}
+
+	for name, r := range map[string]io.Reader{
+		"coalesced":     bytes.NewReader(stream),
+		"partial reads": iotest.OneByteReader(bytes.NewReader(stream)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := NewFrameReader(r)
+			for _, e := range expected {
+				msg, err := f.ReadMessage()
+				if err != nil || !reflect.DeepEqual(e, msg) {
+					t.Fatal(msg, err)
+				}
+			}
+			if _, err := f.ReadMessage(); err != io.EOF {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderLimits(t *testing.T) {
+	f := NewFrameReader(bytes.NewReader([]byte{0, 0, 0, 9, byte(MsgPiece), 0}))
+	f.MaxFrameSize = 8
+	if _, err := f.ReadFrame(); !errors.Is(err, ErrProtocol) {
+		t.Error(err)
+	}
+
+	f = NewFrameReader(bytes.NewReader([]byte{0, 0, 0, 9, byte(MsgPiece), 0}))
+	if _, err := f.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,340 @@
+// Package peer implements the BitTorrent peer wire protocol.
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// MessageCode identifies the type of a peer wire message.
+type MessageCode uint8
+
+const (
+	MsgChoke MessageCode = iota
+	MsgUnchoke
+	MsgInterested
+	MsgNotInterested
+	MsgHave
+	MsgBitfield
+	MsgRequest
+	MsgPiece
+	MsgCancel
+	MsgPort
+	MsgHandshake = 255 // Doesn't actually have an ID.
+)
+
+var messageNames = [...]string{
+	MsgChoke:         "choke",
+	MsgUnchoke:       "unchoke",
+	MsgInterested:    "interested",
+	MsgNotInterested: "not interested",
+	MsgHave:          "have",
+	MsgBitfield:      "bitfield",
+	MsgRequest:       "request",
+	MsgPiece:         "piece",
+	MsgCancel:        "cancel",
+	MsgPort:          "port",
+}
+
+func (c MessageCode) String() string {
+	if c == MsgHandshake {
+		return "handshake"
+	}
+	if int(c) < len(messageNames) {
+		return messageNames[c]
+	}
+	return fmt.Sprintf("message %d", uint8(c))
+}
+
+// protocolString identifies the BitTorrent protocol in handshakes.
+const protocolString = "BitTorrent protocol"
+
+// HandshakeSize is the length of a handshake on the wire.
+const HandshakeSize = 1 + len(protocolString) + 8 + 20 + 20
+
+// HandshakeMsg is the first message exchanged on a peer connection.
+type HandshakeMsg struct {
+	Reserved [8]byte // capability flags, see Capability
+	InfoHash []byte
+	PeerId   []byte
+}
+
+// Capability is an extension advertised by a bit of the handshake's
+// reserved bytes.
+type Capability struct {
+	index int
+	mask  byte
+}
+
+var (
+	DHT       = Capability{7, 0x01} // BEP 5
+	Fast      = Capability{7, 0x04} // BEP 6
+	Extension = Capability{5, 0x10} // BEP 10
+)
+
+// Has reports whether the handshake advertises c.
+func (m HandshakeMsg) Has(c Capability) bool {
+	return m.Reserved[c.index]&c.mask != 0
+}
+
+// Set advertises c in the handshake.
+func (m *HandshakeMsg) Set(c Capability) {
+	m.Reserved[c.index] |= c.mask
+}
+
+// NewHandshakeMsg builds a handshake for the torrent identified by infoHash
+// from the peer identified by peerId, advertising no capabilities.
+func NewHandshakeMsg(infoHash, peerId [20]byte) HandshakeMsg {
+	result := HandshakeMsg{
+		InfoHash: make([]byte, 20),
+		PeerId:   make([]byte, 20),
+	}
+
+	copy(result.InfoHash, infoHash[:])
+	copy(result.PeerId, peerId[:])
+
+	return result
+}
+
+// ParseHandshake parses a handshake in its wire format, checking that it
+// is for the BitTorrent protocol.
+func ParseHandshake(b []byte) (HandshakeMsg, error) {
+	if len(b) != HandshakeSize {
+		return HandshakeMsg{}, &MessageError{Msg: fmt.Sprintf("handshake of %d bytes, expected %d", len(b), HandshakeSize)}
+	}
+	if int(b[0]) != len(protocolString) || string(b[1:20]) != protocolString {
+		return HandshakeMsg{}, &MessageError{Msg: fmt.Sprintf("unknown protocol %q", b[1:min(1+int(b[0]), len(b))])}
+	}
+
+	result := HandshakeMsg{
+		InfoHash: bytes.Clone(b[28:48]),
+		PeerId:   bytes.Clone(b[48:68]),
+	}
+	copy(result.Reserved[:], b[20:28])
+	return result, nil
+}
+
+func (m HandshakeMsg) ToBytes() []byte {
+	result := make([]byte, HandshakeSize)
+	result[0] = byte(len(protocolString))
+	copy(result[1:20], protocolString)
+	copy(result[20:28], m.Reserved[:])
+	copy(result[28:48], m.InfoHash)
+	copy(result[48:68], m.PeerId)
+	return result
+}
+
+// KeepAliveMsg is an empty frame, sent to keep an idle connection open.
+type KeepAliveMsg struct{}
+
+type ChokeMsg struct{}
+
+type UnchokeMsg struct{}
+
+type InterestedMsg struct{}
+
+type NotInterestedMsg struct{}
+
+type HaveMsg struct {
+	PieceIndex int32
+}
+
+type BitfieldMsg struct {
+	Bitfield []byte
+}
+
+type RequestMsg struct {
+	Index  uint32
+	Begin  uint32
+	Length uint32
+}
+
+type PieceMsg struct {
+	Index uint32
+	Begin uint32
+	Block []byte
+}
+
+type CancelMsg struct {
+	Index  uint32
+	Begin  uint32
+	Length uint32
+}
+
+type PortMsg struct {
+	ListenPort uint16
+}
+
+// Message is implemented by every peer wire message type.
+type Message interface {
+	__isPeerMessage()
+}
+
+func (HandshakeMsg) __isPeerMessage()     {}
+func (KeepAliveMsg) __isPeerMessage()     {}
+func (ChokeMsg) __isPeerMessage()         {}
+func (UnchokeMsg) __isPeerMessage()       {}
+func (InterestedMsg) __isPeerMessage()    {}
+func (NotInterestedMsg) __isPeerMessage() {}
+func (HaveMsg) __isPeerMessage()          {}
+func (BitfieldMsg) __isPeerMessage()      {}
+func (RequestMsg) __isPeerMessage()       {}
+func (PieceMsg) __isPeerMessage()         {}
+func (CancelMsg) __isPeerMessage()        {}
+func (PortMsg) __isPeerMessage()          {}
+
+// newFrame returns a frame for a message of type code with room for
+// payloadSize bytes of payload after the ID.
+func newFrame(code MessageCode, payloadSize int) []byte {
+	result := make([]byte, 5+payloadSize)
+	binary.BigEndian.PutUint32(result[:4], uint32(1+payloadSize))
+	result[4] = byte(code)
+	return result
+}
+
+// ToBytes serializes msg in its wire format, length prefix included.
+func ToBytes(msg Message) []byte {
+	switch m := msg.(type) {
+	case KeepAliveMsg:
+		return make([]byte, 4)
+
+	case ChokeMsg:
+		return newFrame(MsgChoke, 0)
+
+	case UnchokeMsg:
+		return newFrame(MsgUnchoke, 0)
+
+	case InterestedMsg:
+		return newFrame(MsgInterested, 0)
+
+	case NotInterestedMsg:
+		return newFrame(MsgNotInterested, 0)
+
+	case HaveMsg:
+		result := newFrame(MsgHave, 4)
+		binary.BigEndian.PutUint32(result[5:9], uint32(m.PieceIndex))
+		return result
+
+	case BitfieldMsg:
+		result := newFrame(MsgBitfield, len(m.Bitfield))
+		copy(result[5:], m.Bitfield)
+		return result
+
+	case RequestMsg:
+		result := newFrame(MsgRequest, 12)
+		binary.BigEndian.PutUint32(result[5:9], m.Index)
+		binary.BigEndian.PutUint32(result[9:13], m.Begin)
+		binary.BigEndian.PutUint32(result[13:17], m.Length)
+		return result
+
+	case PieceMsg:
+		result := newFrame(MsgPiece, 8+len(m.Block))
+		binary.BigEndian.PutUint32(result[5:9], m.Index)
+		binary.BigEndian.PutUint32(result[9:13], m.Begin)
+		copy(result[13:], m.Block)
+		return result
+
+	case CancelMsg:
+		result := newFrame(MsgCancel, 12)
+		binary.BigEndian.PutUint32(result[5:9], m.Index)
+		binary.BigEndian.PutUint32(result[9:13], m.Begin)
+		binary.BigEndian.PutUint32(result[13:17], m.Length)
+		return result
+
+	case PortMsg:
+		result := newFrame(MsgPort, 2)
+		binary.BigEndian.PutUint16(result[5:7], m.ListenPort)
+		return result
+	}
+
+	return nil
+}
+
+// payloadSizes are the exact payload lengths of fixed-size messages.
+var payloadSizes = map[MessageCode]int{
+	MsgChoke:         0,
+	MsgUnchoke:       0,
+	MsgInterested:    0,
+	MsgNotInterested: 0,
+	MsgHave:          4,
+	MsgRequest:       12,
+	MsgCancel:        12,
+	MsgPort:          2,
+}
+
+// FromBytes parses a single message in its wire format, length prefix
+// included. A frame whose length is zero is a KeepAliveMsg.
+func FromBytes(b []byte) (Message, error) {
+	if len(b) < 4 {
+		return nil, &MessageError{Msg: fmt.Sprintf("frame of %d bytes has no length prefix", len(b))}
+	}
+	length := binary.BigEndian.Uint32(b[:4])
+	if uint64(length) != uint64(len(b)-4) {
+		return nil, &MessageError{Msg: fmt.Sprintf("frame declares %d bytes but has %d", length, len(b)-4)}
+	}
+	if length == 0 {
+		return KeepAliveMsg{}, nil
+	}
+
+	code := MessageCode(b[4])
+	payload := b[5:]
+	if size, ok := payloadSizes[code]; ok && len(payload) != size {
+		return nil, &MessageError{Msg: fmt.Sprintf("%v payload of %d bytes, expected %d", code, len(payload), size)}
+	}
+
+	switch code {
+	case MsgChoke:
+		return ChokeMsg{}, nil
+
+	case MsgUnchoke:
+		return UnchokeMsg{}, nil
+
+	case MsgInterested:
+		return InterestedMsg{}, nil
+
+	case MsgNotInterested:
+		return NotInterestedMsg{}, nil
+
+	case MsgHave:
+		return HaveMsg{
+			PieceIndex: int32(binary.BigEndian.Uint32(payload[0:4])),
+		}, nil
+
+	case MsgBitfield:
+		return BitfieldMsg{
+			Bitfield: payload,
+		}, nil
+
+	case MsgRequest:
+		return RequestMsg{
+			Index:  binary.BigEndian.Uint32(payload[0:4]),
+			Begin:  binary.BigEndian.Uint32(payload[4:8]),
+			Length: binary.BigEndian.Uint32(payload[8:12]),
+		}, nil
+
+	case MsgPiece:
+		if len(payload) < 8 {
+			return nil, &MessageError{Msg: fmt.Sprintf("%v payload of %d bytes, expected at least 8", code, len(payload))}
+		}
+		return PieceMsg{
+			Index: binary.BigEndian.Uint32(payload[0:4]),
+			Begin: binary.BigEndian.Uint32(payload[4:8]),
+			Block: payload[8:],
+		}, nil
+
+	case MsgCancel:
+		return CancelMsg{
+			Index:  binary.BigEndian.Uint32(payload[0:4]),
+			Begin:  binary.BigEndian.Uint32(payload[4:8]),
+			Length: binary.BigEndian.Uint32(payload[8:12]),
+		}, nil
+
+	case MsgPort:
+		return PortMsg{
+			ListenPort: binary.BigEndian.Uint16(payload[0:2]),
+		}, nil
+	}
+
+	return nil, &MessageError{Msg: fmt.Sprintf("unknown message id %d", code)}
+}
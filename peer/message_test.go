@@ -0,0 +1,81 @@
+package peer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMessageGolden(t *testing.T) {
+	cases := []struct {
+		code    MessageCode
+		msg     Message
+		encoded []byte
+	}{
+		{MsgChoke, ChokeMsg{}, []byte{0, 0, 0, 1, 0}},
+		{MsgUnchoke, UnchokeMsg{}, []byte{0, 0, 0, 1, 1}},
+		{MsgInterested, InterestedMsg{}, []byte{0, 0, 0, 1, 2}},
+		{MsgNotInterested, NotInterestedMsg{}, []byte{0, 0, 0, 1, 3}},
+		{MsgHave, HaveMsg{PieceIndex: 0x01020304}, []byte{0, 0, 0, 5, 4, 1, 2, 3, 4}},
+		{MsgBitfield, BitfieldMsg{Bitfield: []byte{0xf0, 0x01}}, []byte{0, 0, 0, 3, 5, 0xf0, 0x01}},
+		{MsgRequest, RequestMsg{Index: 1, Begin: 0x4000, Length: 0x4000},
+			[]byte{0, 0, 0, 13, 6, 0, 0, 0, 1, 0, 0, 0x40, 0, 0, 0, 0x40, 0}},
+		{MsgPiece, PieceMsg{Index: 2, Begin: 3, Block: []byte("abc")},
+			[]byte{0, 0, 0, 12, 7, 0, 0, 0, 2, 0, 0, 0, 3, 'a', 'b', 'c'}},
+		{MsgCancel, CancelMsg{Index: 1, Begin: 0x4000, Length: 0x4000},
+			[]byte{0, 0, 0, 13, 8, 0, 0, 0, 1, 0, 0, 0x40, 0, 0, 0, 0x40, 0}},
+		{MsgPort, PortMsg{ListenPort: 6881}, []byte{0, 0, 0, 3, 9, 0x1a, 0xe1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.code.String(), func(t *testing.T) {
+			encoded := ToBytes(c.msg)
+			if !bytes.Equal(c.encoded, encoded) {
+				t.Errorf("encoded as %v, expected %v", encoded, c.encoded)
+			}
+			decoded, err := FromBytes(encoded)
+			if err != nil || !reflect.DeepEqual(c.msg, decoded) {
+				t.Error(decoded, err)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	messages := []Message{
+		KeepAliveMsg{},
+		HaveMsg{PieceIndex: -1},
+		BitfieldMsg{Bitfield: []byte{}},
+		PieceMsg{Index: 0xffffffff, Begin: 0xffffffff, Block: []byte{}},
+		PieceMsg{Index: 7, Begin: 0, Block: bytes.Repeat([]byte{0xaa}, int(BLOCK_SIZE))},
+		RequestMsg{},
+		PortMsg{ListenPort: 0xffff},
+	}
+
+	for _, msg := range messages {
+		encoded := ToBytes(msg)
+		decoded, err := FromBytes(encoded)
+		if err != nil || !reflect.DeepEqual(msg, decoded) {
+			t.Errorf("%T: got %v, %v", msg, decoded, err)
+		}
+	}
+}
+
+func TestHandshakeGolden(t *testing.T) {
+	msg := NewHandshakeMsg([20]byte{1, 2, 3}, [20]byte{'-', 'T', 'C'})
+	encoded := msg.ToBytes()
+
+	expected := append([]byte{19}, "BitTorrent protocol"...)
+	expected = append(expected, make([]byte, 8)...)
+	expected = append(expected, 1, 2, 3)
+	expected = append(expected, make([]byte, 17)...)
+	expected = append(expected, '-', 'T', 'C')
+	expected = append(expected, make([]byte, 17)...)
+	if !bytes.Equal(expected, encoded) {
+		t.Error(encoded)
+	}
+
+	if decoded, err := ParseHandshake(encoded); err != nil || !reflect.DeepEqual(msg, decoded) {
+		t.Error(decoded, err)
+	}
+}
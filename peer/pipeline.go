@@ -0,0 +1,78 @@
+package peer
+
+import (
+	"math"
+	"time"
+)
+
+// Default bounds of the request queue of a Conn.
+const (
+	DefaultMinQueueDepth = 4
+	DefaultMaxQueueDepth = 256
+)
+
+// pipeline measures a peer's download rate and latency to decide how many
+// block requests to keep outstanding: twice the bandwidth-delay product,
+// so the peer never runs dry while the queue keeps growing for as long as
+// more requests still make it faster.
+//
+// The rate is the bytes received over the time the peer was busy sending
+// them, summed over the last blocks: blocks often arrive several at once,
+// so the gap before a single block says little.
+type pipeline struct {
+	samples     [rateWindow]rateSample // ring of the last blocks measured
+	next        int                    // index of the oldest sample
+	latency     time.Duration          // lowest request to reply time seen
+	lastArrival time.Time
+}
+
+// rateWindow is the number of blocks the rate is measured over.
+const rateWindow = 16
+
+// rateSample is a block of n bytes that took gap to arrive after the
+// previous one.
+type rateSample struct {
+	n   int
+	gap time.Duration
+}
+
+// received records the arrival at now of a block of n bytes requested at
+// sent. If it was requested before the last arrival, the peer was busy
+// since and the gap between arrivals measures the rate; otherwise the gap
+// includes time the peer was idle, or choking us, and is not a sample.
+func (p *pipeline) received(n int, sent, now time.Time) {
+	if rtt := now.Sub(sent); p.latency == 0 || rtt < p.latency {
+		p.latency = max(rtt, time.Microsecond)
+	}
+
+	if !p.lastArrival.IsZero() && !sent.After(p.lastArrival) {
+		p.samples[p.next] = rateSample{n, now.Sub(p.lastArrival)}
+		p.next = (p.next + 1) % rateWindow
+	}
+	p.lastArrival = now
+}
+
+// rate returns the download rate in bytes per second, or zero until the
+// samples span at least one round trip: a single burst of blocks tells
+// nothing about the rate.
+func (p *pipeline) rate() float64 {
+	n, busy := 0, time.Duration(0)
+	for _, s := range p.samples {
+		n += s.n
+		busy += s.gap
+	}
+	if busy <= 0 || busy < p.latency {
+		return 0
+	}
+	return float64(n) / busy.Seconds()
+}
+
+// depth returns the number of requests to keep outstanding, between lo
+// and hi.
+func (p *pipeline) depth(lo, hi int) int {
+	bdp := p.rate() * p.latency.Seconds() / float64(BLOCK_SIZE)
+	if math.IsNaN(bdp) || math.IsInf(bdp, 0) {
+		return lo
+	}
+	return min(max(int(math.Ceil(2*bdp)), lo), hi)
+}
@@ -0,0 +1,107 @@
+package peer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPipelineDepth(t *testing.T) {
+	var p pipeline
+	if d := p.depth(4, 256); d != 4 {
+		t.Error("unmeasured peer should get the minimum depth, got", d)
+	}
+
+	// A peer sending a block every 16ms with 100ms of latency: 1 MiB/s.
+	start := time.Unix(0, 0)
+	for i := range 50 {
+		arrival := start.Add(100*time.Millisecond + time.Duration(i)*16*time.Millisecond)
+		p.received(int(BLOCK_SIZE), arrival.Add(-100*time.Millisecond), arrival)
+	}
+	if d := p.depth(4, 256); d < 12 || d > 14 {
+		t.Error("expected twice the bandwidth-delay product of 6.25 blocks, got", d)
+	}
+	if d := p.depth(4, 8); d != 8 {
+		t.Error("depth should be capped, got", d)
+	}
+
+	// After ten idle seconds, a second burst at the same rate: the gap
+	// before its first block is not transfer time.
+	start = start.Add(10 * time.Second)
+	for i := range 10 {
+		arrival := start.Add(100*time.Millisecond + time.Duration(i)*16*time.Millisecond)
+		p.received(int(BLOCK_SIZE), start.Add(time.Duration(i)*16*time.Millisecond), arrival)
+		if d := p.depth(4, 256); d < 12 || d > 14 {
+			t.Fatal("block", i, "after the gap gave depth", d)
+		}
+	}
+}
+
+func TestPipelineBursts(t *testing.T) {
+	var p pipeline
+
+	// A 1 MiB/s peer with 100ms of latency whose blocks arrive four at
+	// once every 64ms.
+	start := time.Unix(0, 0)
+	for burst := range 20 {
+		arrival := start.Add(100*time.Millisecond + time.Duration(burst)*64*time.Millisecond)
+		for range 4 {
+			p.received(int(BLOCK_SIZE), arrival.Add(-100*time.Millisecond), arrival)
+			// Until the window fills up, a burst's blocks may count
+			// without the gap before it.
+			if d := p.depth(4, 256); d > 20 {
+				t.Fatal("burst", burst, "gave depth", d)
+			}
+		}
+	}
+	if d := p.depth(4, 256); d < 12 || d > 14 {
+		t.Error("expected twice the bandwidth-delay product of 6.25 blocks, got", d)
+	}
+}
+
+func TestDownloadPieceOutOfOrder(t *testing.T) {
+	pieceLength := 3 * int(BLOCK_SIZE)
+	data := make([]byte, 2*pieceLength+100)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	info := newTestTorrent(data, pieceLength)
+
+	conn, remote := pipeConn(t, [20]byte{})
+	conn.Torrent = info
+	go func() {
+		// Answer each piece's requests only once all of them arrived, last
+		// block first.
+		s := newScript(t, remote)
+		s.send(UnchokeMsg{})
+		for index := range info.NumPieces() {
+			var requests []RequestMsg
+			for len(requests)*int(BLOCK_SIZE) < info.PieceSize(index) {
+				msg, err := s.frames.ReadMessage()
+				if err != nil {
+					return
+				}
+				if m, ok := msg.(RequestMsg); ok {
+					requests = append(requests, m)
+				}
+			}
+			for i := len(requests) - 1; i >= 0; i-- {
+				m := requests[i]
+				start := int(m.Index)*pieceLength + int(m.Begin)
+				s.send(PieceMsg{Index: m.Index, Begin: m.Begin, Block: data[start : start+int(m.Length)]})
+			}
+		}
+	}()
+
+	var result []byte
+	for index := range info.NumPieces() {
+		piece, err := conn.DownloadPiece(index)
+		if err != nil {
+			t.Fatal(index, err)
+		}
+		result = append(result, piece...)
+	}
+	if !bytes.Equal(data, result) {
+		t.Error("downloaded data differs")
+	}
+}
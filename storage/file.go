@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"torrent-client/metainfo"
+)
+
+// File is a Storage keeping the data in the files described by the
+// torrent, laid out under a download directory. BEP 47 padding files are
+// not created: they read as zeros and writes to them are dropped.
+type File struct {
+	Info  metainfo.Info
+	files []*os.File // per entry of Info.FileList, nil for padding
+}
+
+// OpenFile opens, creating them as needed, the files of info under dir.
+// Existing files are kept, and truncated or extended to their size in the
+// torrent.
+func OpenFile(info metainfo.Info, dir string) (*File, error) {
+	s := &File{Info: info}
+	for _, f := range info.FileList() {
+		if f.IsPadding() {
+			s.files = append(s.files, nil)
+			continue
+		}
+
+		path := filepath.Join(dir, f.FilePath())
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			s.Close()
+			return nil, err
+		}
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.files = append(s.files, file)
+		// Leave files of the right size untouched, keeping their
+		// modification time for resuming.
+		if info, err := file.Stat(); err != nil || info.Size() != int64(f.Length) {
+			if err := file.Truncate(int64(f.Length)); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *File) ReadAt(p []byte, index, offset int) (int, error) {
+	list, err := segments(s.Info, index, offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, i := range list {
+		part := p[n : n+i.Length]
+		if file := s.files[i.File]; file == nil {
+			clear(part)
+		} else if _, err := file.ReadAt(part, int64(i.Offset)); err != nil {
+			return n, err
+		}
+		n += i.Length
+	}
+	return n, nil
+}
+
+func (s *File) WriteAt(p []byte, index, offset int) (int, error) {
+	list, err := segments(s.Info, index, offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, i := range list {
+		if file := s.files[i.File]; file != nil {
+			if _, err := file.WriteAt(p[n:n+i.Length], int64(i.Offset)); err != nil {
+				return n, err
+			}
+		}
+		n += i.Length
+	}
+	return n, nil
+}
+
+func (s *File) Flush() error {
+	var errs []error
+	for _, file := range s.files {
+		if file != nil {
+			errs = append(errs, file.Sync())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *File) Close() error {
+	var errs []error
+	for _, file := range s.files {
+		if file != nil {
+			errs = append(errs, file.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
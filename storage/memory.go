@@ -0,0 +1,47 @@
+package storage
+
+import "torrent-client/metainfo"
+
+// Memory is a Storage holding the data in memory.
+type Memory struct {
+	Info metainfo.Info
+	// Files holds the data, one buffer per entry of Info.FileList.
+	Files [][]byte
+}
+
+// NewMemory returns an empty Memory for info.
+func NewMemory(info metainfo.Info) *Memory {
+	m := &Memory{Info: info}
+	for _, f := range info.FileList() {
+		m.Files = append(m.Files, make([]byte, f.Length))
+	}
+	return m
+}
+
+func (m *Memory) ReadAt(p []byte, index, offset int) (int, error) {
+	list, err := segments(m.Info, index, offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range list {
+		n += copy(p[n:], m.Files[s.File][s.Offset:s.Offset+s.Length])
+	}
+	return n, nil
+}
+
+func (m *Memory) WriteAt(p []byte, index, offset int) (int, error) {
+	list, err := segments(m.Info, index, offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range list {
+		n += copy(m.Files[s.File][s.Offset:s.Offset+s.Length], p[n:])
+	}
+	return n, nil
+}
+
+func (m *Memory) Flush() error { return nil }
+
+func (m *Memory) Close() error { return nil }
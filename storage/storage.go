@@ -0,0 +1,35 @@
+// Package storage keeps the data of a torrent, addressed by piece, in
+// memory or in the files it describes.
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"torrent-client/metainfo"
+)
+
+// ErrBounds is returned for reads and writes past the end of a piece.
+var ErrBounds = errors.New("storage: access out of piece bounds")
+
+// Storage holds the data of a torrent. Offsets are relative to the start
+// of a piece, and an access may not extend past its end.
+type Storage interface {
+	// ReadAt reads len(p) bytes of the piece at index, starting at offset.
+	ReadAt(p []byte, index, offset int) (int, error)
+	// WriteAt writes p to the piece at index, starting at offset.
+	WriteAt(p []byte, index, offset int) (int, error)
+	// Flush commits the data written so far to stable storage.
+	Flush() error
+	// Close releases the storage. It does not flush.
+	Close() error
+}
+
+// segments maps n bytes at offset in the piece at index onto the files of
+// info, checking that they lie within the piece.
+func segments(info metainfo.Info, index, offset, n int) ([]metainfo.Segment, error) {
+	if index < 0 || index >= info.NumPieces() || offset < 0 || offset+n > info.PieceSize(index) {
+		return nil, fmt.Errorf("%w: %d bytes at %d in piece %d", ErrBounds, n, offset, index)
+	}
+	return info.Locate(index*info.PieceLength+offset, n), nil
+}
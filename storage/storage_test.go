@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"torrent-client/metainfo"
+)
+
+// multiFile has three pieces of 4 bytes over files of 3, 0, 2 and 7 bytes,
+// the 2-byte one being padding.
+var multiFile = metainfo.Info{
+	Name:        "dir",
+	PieceLength: 4,
+	Pieces:      make([]byte, 3*20),
+	Files: []metainfo.File{
+		{Length: 3, Path: []string{"a"}},
+		{Length: 0, Path: []string{"empty"}},
+		{Length: 2, Path: []string{".pad", "2"}, Attr: "p"},
+		{Length: 7, Path: []string{"sub", "b"}},
+	},
+}
+
+var singleFile = metainfo.Info{Name: "file", Length: 6, PieceLength: 4, Pieces: make([]byte, 2*20)}
+
+// roundTrip writes pieces to s and reads them back.
+func roundTrip(t *testing.T, s Storage, pieces []string) {
+	t.Helper()
+	for i, piece := range pieces {
+		if n, err := s.WriteAt([]byte(piece), i, 0); err != nil || n != len(piece) {
+			t.Fatal(i, n, err)
+		}
+	}
+	for i, piece := range pieces {
+		buf := make([]byte, len(piece)-1)
+		if _, err := s.ReadAt(buf, i, 1); err != nil || string(buf) != piece[1:] {
+			t.Error(i, string(buf), err)
+		}
+	}
+
+	if _, err := s.WriteAt([]byte("xy"), 0, 3); !errors.Is(err, ErrBounds) {
+		t.Error("write past the end of a piece:", err)
+	}
+	if _, err := s.ReadAt(make([]byte, 1), len(pieces), 0); !errors.Is(err, ErrBounds) {
+		t.Error("read past the last piece:", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	s := NewMemory(multiFile)
+	roundTrip(t, s, []string{"012\x00", "\x00567", "89ab"})
+	for i, expected := range []string{"012", "", "\x00\x00", "56789ab"} {
+		if string(s.Files[i]) != expected {
+			t.Errorf("file %d holds %q, expected %q", i, s.Files[i], expected)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFile(multiFile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, s, []string{"012\x00", "\x00567", "89ab"})
+
+	// The padding file is not stored, and reads as zeros.
+	if _, err := s.WriteAt([]byte("x"), 0, 3); err != nil {
+		t.Fatal(err)
+	}
+	piece := make([]byte, 4)
+	if _, err := s.ReadAt(piece, 0, 0); err != nil || !bytes.Equal(piece, []byte{'0', '1', '2', 0}) {
+		t.Error(piece, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{"dir/a": "012", "dir/empty": "", "dir/sub/b": "56789ab"} {
+		if data, err := os.ReadFile(filepath.Join(dir, path)); err != nil || string(data) != expected {
+			t.Errorf("%s holds %q, %v", path, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir", ".pad")); !os.IsNotExist(err) {
+		t.Error("padding file created:", err)
+	}
+}
+
+func TestFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFile(singleFile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, s, []string{"0123", "45"})
+	s.Close()
+
+	// Existing data is kept.
+	s, err = OpenFile(singleFile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	piece := make([]byte, 2)
+	if _, err := s.ReadAt(piece, 1, 0); err != nil || string(piece) != "45" {
+		t.Error(string(piece), err)
+	}
+}
@@ -0,0 +1,21 @@
+package tracker
+
+import "errors"
+
+// ErrInvalidResponse is matched by errors caused by a tracker reply that
+// could not be understood.
+var ErrInvalidResponse = errors.New("tracker: invalid response")
+
+// ErrScrapeUnsupported is returned when a tracker's announce URL has no
+// conventional scrape counterpart.
+var ErrScrapeUnsupported = errors.New("tracker: scrape not supported")
+
+// FailureError is returned when the tracker rejects an announce or scrape,
+// carrying the human readable reason it gave.
+type FailureError struct {
+	Reason string
+}
+
+func (e *FailureError) Error() string {
+	return "tracker: request failed: " + e.Reason
+}
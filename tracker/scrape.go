@@ -0,0 +1,121 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"torrent-client/bencode"
+)
+
+// ScrapeStats is a tracker's summary of the swarm for one torrent.
+type ScrapeStats struct {
+	Seeders   int
+	Completed int
+	Leechers  int
+}
+
+// ScrapeURL derives the scrape URL of an HTTP tracker from its announce
+// URL: the last path segment must start with "announce", which is replaced
+// by "scrape". Other URLs return ErrScrapeUnsupported.
+func ScrapeURL(announce string) (string, error) {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return "", err
+	}
+
+	i := strings.LastIndexByte(u.Path, '/')
+	if !strings.HasPrefix(u.Path[i+1:], "announce") {
+		return "", fmt.Errorf("%w: %s", ErrScrapeUnsupported, announce)
+	}
+	u.Path = u.Path[:i+1] + "scrape" + strings.TrimPrefix(u.Path[i+1:], "announce")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// Scrape asks the tracker whose announce URL is rawURL, over HTTP or UDP
+// depending on its scheme, for statistics about the torrents identified by
+// hashes. They are returned in the same order.
+func Scrape(ctx context.Context, rawURL string, hashes ...[20]byte) ([]ScrapeStats, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return scrapeHTTP(ctx, rawURL, hashes)
+	case "udp":
+		return DefaultUDPClient.Scrape(ctx, rawURL, hashes...)
+	default:
+		return nil, fmt.Errorf("tracker: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// scrapeResponse is the body of an HTTP scrape reply.
+type scrapeResponse struct {
+	FailureReason string `bencoded:"failure reason,omitempty"`
+	Files         map[string]struct {
+		Complete   int
+		Downloaded int
+		Incomplete int
+	}
+}
+
+func scrapeHTTP(ctx context.Context, announce string, hashes [][20]byte) ([]ScrapeStats, error) {
+	path, err := ScrapeURL(announce)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	for _, hash := range hashes {
+		params.Add("info_hash", string(hash[:]))
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, path+separator+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	return parseScrapeResponse(body, hashes)
+}
+
+// parseScrapeResponse extracts the statistics of hashes from the bencoded
+// body of a scrape reply. Torrents the tracker does not know about are
+// reported with zero counts.
+func parseScrapeResponse(body []byte, hashes [][20]byte) ([]ScrapeStats, error) {
+	var reply scrapeResponse
+	if err := bencode.Unmarshal(body, &reply); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if reply.FailureReason != "" {
+		return nil, &FailureError{Reason: reply.FailureReason}
+	}
+
+	result := make([]ScrapeStats, len(hashes))
+	for i, hash := range hashes {
+		file := reply.Files[string(hash[:])]
+		result[i] = ScrapeStats{
+			Seeders:   file.Complete,
+			Completed: file.Downloaded,
+			Leechers:  file.Incomplete,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestScrapeURL(t *testing.T) {
+	cases := []struct {
+		announce string
+		expected string
+	}{
+		{"http://example.com/announce", "http://example.com/scrape"},
+		{"http://example.com/x/announce", "http://example.com/x/scrape"},
+		{"http://example.com/announce.php", "http://example.com/scrape.php"},
+		{"http://example.com/announce?x2%0644", "http://example.com/scrape?x2%0644"},
+		{"http://example.com/a%20b/announce", "http://example.com/a%20b/scrape"},
+		{"http://example.com/a", ""},
+		{"http://example.com/announce/x", ""},
+		{"http://example.com/x/Announce", ""},
+	}
+
+	for _, c := range cases {
+		result, err := ScrapeURL(c.announce)
+		if c.expected == "" {
+			if !errors.Is(err, ErrScrapeUnsupported) {
+				t.Error(c.announce, result, err)
+			}
+		} else if err != nil || result != c.expected {
+			t.Error(c.announce, result, err)
+		}
+	}
+}
+
+func TestScrapeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scrape" || len(r.URL.Query()["info_hash"]) != 2 {
+			w.Write([]byte("d14:failure reason11:bad requeste"))
+			return
+		}
+		w.Write([]byte("d5:filesd20:\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+			"d8:completei5e10:downloadedi50e10:incompletei10eeee"))
+	}))
+	defer server.Close()
+
+	stats, err := Scrape(context.Background(), server.URL+"/announce", [20]byte{1}, [20]byte{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScrapeStats{{Seeders: 5, Completed: 50, Leechers: 10}, {}}
+	if !reflect.DeepEqual(expected, stats) {
+		t.Error(stats)
+	}
+
+	var failure *FailureError
+	if _, err := Scrape(context.Background(), server.URL+"/announce", [20]byte{1}); !errors.As(err, &failure) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,177 @@
+package tracker
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Stats are the transfer counters reported to trackers.
+type Stats struct {
+	Uploaded   int
+	Downloaded int
+	Left       int
+}
+
+// Session announces one torrent to its trackers for as long as it is being
+// shared: started when it begins, periodically as the trackers ask,
+// completed when the download finishes and stopped when it ends.
+type Session struct {
+	Trackers *List
+	InfoHash [20]byte
+	PeerID   [20]byte
+	Port     uint16
+	// IPv6 is our IPv6 address, announced so trackers can hand it to IPv6
+	// peers even when announcing over IPv4.
+	IPv6 netip.Addr
+	// NumWant is the number of peers asked for; zero leaves it to the
+	// tracker.
+	NumWant int
+	// Stats returns the counters to send with each announce.
+	Stats func() Stats
+	// RetryInterval is the delay before the first retry when every tracker
+	// failed; it doubles with each further failure, up to DefaultInterval.
+	RetryInterval time.Duration
+	// DefaultInterval is used when a tracker does not give an interval.
+	DefaultInterval time.Duration
+	// StopTimeout bounds the stopped announce sent when Run returns.
+	StopTimeout time.Duration
+
+	key uint32
+
+	mu        sync.Mutex
+	started   bool
+	completed bool
+	next      time.Time
+}
+
+// NewSession returns a Session announcing the torrent identified by
+// infoHash to trackers, as the peer peerID listening on port.
+func NewSession(trackers *List, infoHash, peerID [20]byte, port uint16, stats func() Stats) *Session {
+	return &Session{
+		Trackers:        trackers,
+		InfoHash:        infoHash,
+		PeerID:          peerID,
+		Port:            port,
+		Stats:           stats,
+		RetryInterval:   15 * time.Second,
+		DefaultInterval: 30 * time.Minute,
+		StopTimeout:     5 * time.Second,
+		key:             rand.Uint32(),
+	}
+}
+
+// Announce sends event to the trackers along with the current counters. A
+// successful announce schedules the next one run by Run.
+func (s *Session) Announce(ctx context.Context, event Event) (Response, error) {
+	var stats Stats
+	if s.Stats != nil {
+		stats = s.Stats()
+	}
+
+	resp, err := s.Trackers.Announce(ctx, Request{
+		InfoHash:   s.InfoHash,
+		PeerID:     s.PeerID,
+		Port:       s.Port,
+		Uploaded:   stats.Uploaded,
+		Downloaded: stats.Downloaded,
+		Left:       stats.Left,
+		Event:      event,
+		NumWant:    s.NumWant,
+		Key:        s.key,
+		IPv6:       s.IPv6,
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	wait := resp.Wait()
+	if wait == 0 {
+		wait = s.DefaultInterval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event {
+	case EventStarted:
+		s.started = true
+	case EventCompleted:
+		s.completed = true
+	case EventStopped:
+		s.started = false
+	}
+	s.next = time.Now().Add(wait)
+	return resp, nil
+}
+
+// Complete tells the trackers the download has finished. It does nothing
+// if it already did so.
+func (s *Session) Complete(ctx context.Context) error {
+	s.mu.Lock()
+	completed := s.completed
+	s.mu.Unlock()
+	if completed {
+		return nil
+	}
+
+	_, err := s.Announce(ctx, EventCompleted)
+	return err
+}
+
+// Run re-announces the torrent whenever the trackers' interval has passed,
+// starting with a started announce unless one was already sent, and calls
+// handle with the outcome of each. When ctx is done it sends a stopped
+// announce and returns its error.
+func (s *Session) Run(ctx context.Context, handle func(Response, error)) error {
+	retry := s.RetryInterval
+	for {
+		s.mu.Lock()
+		event := EventNone
+		if !s.started {
+			event = EventStarted
+		}
+		wait := time.Until(s.next)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return s.stop()
+		case <-timer.C:
+		}
+
+		resp, err := s.Announce(ctx, event)
+		if ctx.Err() != nil {
+			return s.stop()
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.next = time.Now().Add(retry)
+			s.mu.Unlock()
+			retry = min(2*retry, s.DefaultInterval)
+		} else {
+			retry = s.RetryInterval
+		}
+		if handle != nil {
+			handle(resp, err)
+		}
+	}
+}
+
+// stop sends a stopped announce if a started one was sent.
+func (s *Session) stop() error {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.StopTimeout)
+	defer cancel()
+	_, err := s.Announce(ctx, EventStopped)
+	return err
+}
@@ -0,0 +1,68 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	var mu sync.Mutex
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		w.Write([]byte("d8:intervali1800e12:min intervali60e5:peers0:10:tracker id3:abce"))
+	}))
+	defer server.Close()
+
+	s := NewSession(NewList([][]string{{server.URL + "/announce?passkey=x"}}), [20]byte{1}, [20]byte{2}, 6882,
+		func() Stats { return Stats{Uploaded: 1, Downloaded: 2, Left: 3} })
+	s.NumWant = 30
+	s.IPv6 = netip.MustParseAddr("2001:db8::2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.Run(ctx, func(resp Response, err error) {
+		if err != nil || resp.Interval != 1800 || resp.MinInterval != 60 || resp.TrackerID != "abc" {
+			t.Error(resp, err)
+		}
+		cancel()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Complete(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Complete(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 3 {
+		t.Fatal("expected started, stopped and completed announces, got", queries)
+	}
+	for n, event := range []string{"started", "stopped", "completed"} {
+		q := queries[n]
+		if q.Get("event") != event {
+			t.Error("announce", n, "has event", q.Get("event"), "expected", event)
+		}
+		if q.Get("passkey") != "x" || q.Get("numwant") != "30" || q.Get("port") != "6882" ||
+			q.Get("uploaded") != "1" || q.Get("downloaded") != "2" || q.Get("left") != "3" ||
+			q.Get("ipv6") != "2001:db8::2" {
+			t.Error(q)
+		}
+		if q.Get("key") != queries[0].Get("key") {
+			t.Error("key should stay the same across announces")
+		}
+	}
+	if queries[0].Has("trackerid") || queries[1].Get("trackerid") != "abc" {
+		t.Error("tracker id should be echoed after the first response")
+	}
+}
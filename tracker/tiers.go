@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Status is what is known about one tracker of a List.
+type Status struct {
+	URL          string
+	Tier         int
+	TrackerID    string // as returned by the tracker, echoed when announcing
+	LastError    error
+	Warning      string // from the last successful announce
+	LastAnnounce time.Time
+	NextAnnounce time.Time
+	Seeders      int
+	Leechers     int
+}
+
+// List is a torrent's trackers grouped into tiers as described by BEP 12.
+// Trackers are tried tier by tier; the first to answer is moved to the
+// front of its tier so it is tried first next time.
+type List struct {
+	// Timeout bounds the time given to each tracker before failing over
+	// to the next; zero means no limit.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	tiers [][]*Status
+}
+
+// DefaultTrackerTimeout is the Timeout of a new List. It leaves a UDP
+// tracker time for one retransmission.
+const DefaultTrackerTimeout = 30 * time.Second
+
+// NewList builds a List from tiers of tracker URLs, shuffling each tier.
+func NewList(tiers [][]string) *List {
+	l := &List{Timeout: DefaultTrackerTimeout}
+	for n, urls := range tiers {
+		tier := make([]*Status, len(urls))
+		for i, url := range urls {
+			tier[i] = &Status{URL: url, Tier: n}
+		}
+		rand.Shuffle(len(tier), func(i, j int) {
+			tier[i], tier[j] = tier[j], tier[i]
+		})
+		l.tiers = append(l.tiers, tier)
+	}
+	return l
+}
+
+// Announce sends req to each tracker in turn until one answers, and returns
+// its response. If every tracker fails, or ctx is done, the errors are
+// returned joined.
+func (l *List) Announce(ctx context.Context, req Request) (Response, error) {
+	var errs []error
+	for n := range l.numTiers() {
+		for i := 0; ctx.Err() == nil; i++ {
+			status := l.tracker(n, i)
+			if status == nil {
+				break
+			}
+
+			req.TrackerID = l.trackerID(status)
+			resp, err := l.announce(ctx, status.URL, req)
+			l.update(n, status, resp, err)
+			if err == nil {
+				return resp, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return Response{}, errors.New("tracker: no trackers")
+	}
+	return Response{}, errors.Join(errs...)
+}
+
+// announce sends req to the tracker at url, giving up after Timeout.
+func (l *List) announce(ctx context.Context, url string, req Request) (Response, error) {
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+	return Announce(ctx, url, req)
+}
+
+// Status returns a snapshot of every tracker, in the order they are tried.
+func (l *List) Status() []Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var result []Status
+	for _, tier := range l.tiers {
+		for _, status := range tier {
+			result = append(result, *status)
+		}
+	}
+	return result
+}
+
+func (l *List) numTiers() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.tiers)
+}
+
+func (l *List) trackerID(status *Status) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return status.TrackerID
+}
+
+func (l *List) tracker(tier, i int) *Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i >= len(l.tiers[tier]) {
+		return nil
+	}
+	return l.tiers[tier][i]
+}
+
+// update records the outcome of an announce, promoting the tracker to the
+// front of its tier if it succeeded.
+func (l *List) update(tier int, status *Status, resp Response, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status.LastAnnounce = time.Now()
+	status.LastError = err
+	if err != nil {
+		return
+	}
+	status.NextAnnounce = status.LastAnnounce.Add(resp.Wait())
+	if resp.TrackerID != "" {
+		status.TrackerID = resp.TrackerID
+	}
+	status.Warning = resp.Warning
+	status.Seeders = resp.Complete
+	status.Leechers = resp.Incomplete
+
+	trackers := l.tiers[tier]
+	i := 0
+	for trackers[i] != status {
+		i++
+	}
+	copy(trackers[1:i+1], trackers[:i])
+	trackers[0] = status
+}
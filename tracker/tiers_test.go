@@ -0,0 +1,76 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestListFailover(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d14:failure reason7:go awaye"))
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d8:completei3e10:incompletei4e8:intervali60e5:peers6:\x7f\x00\x00\x01\x1a\xe1e"))
+	}))
+	defer working.Close()
+
+	l := NewList([][]string{{failing.URL + "/announce"}, {failing.URL + "/other", working.URL + "/announce"}})
+	resp, err := l.Announce(context.Background(), Request{Left: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Peers) != 1 || resp.Peers[0] != netip.MustParseAddrPort("127.0.0.1:6881") {
+		t.Error(resp)
+	}
+
+	status := l.Status()
+	if status[0].LastError == nil || status[0].Tier != 0 {
+		t.Error(status[0])
+	}
+	promoted := status[1]
+	if promoted.URL != working.URL+"/announce" || promoted.LastError != nil ||
+		promoted.Seeders != 3 || promoted.Leechers != 4 || promoted.NextAnnounce.IsZero() {
+		t.Error("responding tracker should be first in its tier:", status)
+	}
+}
+
+func TestListUnresponsive(t *testing.T) {
+	// A UDP tracker that never answers.
+	dead, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dead.Close()
+	// An HTTP tracker that never answers either.
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d8:intervali60e5:peers6:\x7f\x00\x00\x01\x1a\xe1e"))
+	}))
+	defer working.Close()
+
+	l := NewList([][]string{{"udp://" + dead.LocalAddr().String() + "/announce"}, {hanging.URL + "/announce"}, {working.URL + "/announce"}})
+	l.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	resp, err := l.Announce(context.Background(), Request{Left: 1})
+	if err != nil || len(resp.Peers) != 1 {
+		t.Fatal(resp, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Error("failover took", elapsed)
+	}
+	for _, status := range l.Status()[:2] {
+		if !errors.Is(status.LastError, context.DeadlineExceeded) {
+			t.Error(status.URL, status.LastError)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package tracker implements the client side of the BitTorrent tracker
+// protocol.
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-client/bencode"
+)
+
+// Request holds the parameters of an announce.
+type Request struct {
+	InfoHash   [20]byte
+	PeerID     [20]byte
+	Port       uint16
+	Uploaded   int
+	Downloaded int
+	Left       int
+	Event      Event
+	NumWant    int        // peers wanted; zero leaves it to the tracker
+	Key        uint32     // identifies this client across IP changes
+	TrackerID  string     // echoed from the tracker's previous response
+	IPv6       netip.Addr // our IPv6 address, if we have one (BEP 7)
+}
+
+// Event tells the tracker why an announce is being sent.
+type Event uint8
+
+const (
+	EventNone Event = iota
+	EventCompleted
+	EventStarted
+	EventStopped
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventCompleted:
+		return "completed"
+	case EventStarted:
+		return "started"
+	case EventStopped:
+		return "stopped"
+	}
+	return ""
+}
+
+// Response is a tracker's reply to an announce.
+type Response struct {
+	Interval    int
+	MinInterval int
+	TrackerID   string
+	Warning     string // message to show the user; the announce still worked
+	Complete    int    // number of seeders
+	Incomplete  int    // number of leechers
+	Peers       []netip.AddrPort
+}
+
+// Wait returns how long to wait before the next regular announce: the
+// tracker's interval, but never less than its minimum interval.
+func (r Response) Wait() time.Duration {
+	return time.Duration(max(r.Interval, r.MinInterval)) * time.Second
+}
+
+// NewResponse parses the bencoded body of a tracker response. Peers may be
+// given either as a compact string or as a list of dictionaries, and IPv6
+// peers as a compact "peers6" string (BEP 7).
+func NewResponse(bencoded []byte) (Response, error) {
+	result := Response{}
+
+	peerInfo, err := bencode.Decode(bencoded)
+	if err != nil {
+		return result, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	peerDict, ok := peerInfo.(map[string]any)
+	if !ok {
+		return result, fmt.Errorf("%w: expected dictionary", ErrInvalidResponse)
+	}
+	if reason, ok := peerDict["failure reason"].(string); ok {
+		return result, &FailureError{Reason: reason}
+	}
+	interval, ok := peerDict["interval"].(int)
+	if !ok {
+		return result, fmt.Errorf("%w: missing interval", ErrInvalidResponse)
+	}
+	result.Interval = interval
+	result.MinInterval, _ = peerDict["min interval"].(int)
+	result.TrackerID, _ = peerDict["tracker id"].(string)
+	result.Warning, _ = peerDict["warning message"].(string)
+	result.Complete, _ = peerDict["complete"].(int)
+	result.Incomplete, _ = peerDict["incomplete"].(int)
+
+	peers6, hasPeers6 := peerDict["peers6"].(string)
+	switch peers := peerDict["peers"].(type) {
+	case string:
+		if len(peers)%6 != 0 {
+			return result, fmt.Errorf("%w: compact peers of %d bytes", ErrInvalidResponse, len(peers))
+		}
+		result.Peers = parseCompactPeers([]byte(peers))
+	case []any:
+		result.Peers, err = parsePeerDicts(peers)
+		if err != nil {
+			return result, err
+		}
+	case nil:
+		if !hasPeers6 {
+			return result, fmt.Errorf("%w: missing list of peers", ErrInvalidResponse)
+		}
+	default:
+		return result, fmt.Errorf("%w: peers of type %T", ErrInvalidResponse, peers)
+	}
+	if hasPeers6 {
+		if len(peers6)%18 != 0 {
+			return result, fmt.Errorf("%w: compact IPv6 peers of %d bytes", ErrInvalidResponse, len(peers6))
+		}
+		result.Peers = append(result.Peers, parseCompactPeers6([]byte(peers6))...)
+	}
+
+	return result, nil
+}
+
+// parseCompactPeers decodes a compact peer list of 4-byte IPv4 addresses
+// followed by 2-byte ports.
+func parseCompactPeers(peers []byte) []netip.AddrPort {
+	return parseCompact(peers, 4)
+}
+
+// parseCompactPeers6 decodes a compact peer list of 16-byte IPv6 addresses
+// followed by 2-byte ports.
+func parseCompactPeers6(peers []byte) []netip.AddrPort {
+	return parseCompact(peers, 16)
+}
+
+func parseCompact(peers []byte, size int) []netip.AddrPort {
+	var result []netip.AddrPort
+	for i := 0; i+size+2 <= len(peers); i += size + 2 {
+		addr, _ := netip.AddrFromSlice(peers[i : i+size])
+		port := binary.BigEndian.Uint16(peers[i+size : i+size+2])
+		result = append(result, netip.AddrPortFrom(addr, port))
+	}
+	return result
+}
+
+// parsePeerDicts decodes the original peer list model: a list of
+// dictionaries with "ip" and "port" keys, and optionally a "peer id".
+// Peers given by host name rather than address are skipped.
+func parsePeerDicts(peers []any) ([]netip.AddrPort, error) {
+	var result []netip.AddrPort
+	for n, i := range peers {
+		peer, ok := i.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: peer %d is not a dictionary", ErrInvalidResponse, n)
+		}
+		ip, ok := peer["ip"].(string)
+		if !ok || ip == "" {
+			return nil, fmt.Errorf("%w: peer %d has no ip", ErrInvalidResponse, n)
+		}
+		port, ok := peer["port"].(int)
+		if !ok || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("%w: peer %d has an invalid port", ErrInvalidResponse, n)
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		result = append(result, netip.AddrPortFrom(addr.Unmap(), uint16(port)))
+	}
+	return result, nil
+}
+
+// DiscoverPeers sends req to the HTTP tracker at path and returns the raw
+// response body.
+func DiscoverPeers(ctx context.Context, path string, req Request) ([]byte, error) {
+	params := url.Values{}
+
+	params.Add("info_hash", string(req.InfoHash[:]))
+	params.Add("peer_id", string(req.PeerID[:]))
+	params.Add("port", strconv.Itoa(int(req.Port)))
+	params.Add("uploaded", strconv.Itoa(req.Uploaded))
+	params.Add("downloaded", strconv.Itoa(req.Downloaded))
+	params.Add("compact", "1")
+	params.Add("left", strconv.Itoa(req.Left))
+	if req.Event != EventNone {
+		params.Add("event", req.Event.String())
+	}
+	if req.NumWant > 0 {
+		params.Add("numwant", strconv.Itoa(req.NumWant))
+	}
+	params.Add("key", fmt.Sprintf("%08x", req.Key))
+	if req.TrackerID != "" {
+		params.Add("trackerid", req.TrackerID)
+	}
+	if req.IPv6.Is6() && !req.IPv6.Is4In6() {
+		params.Add("ipv6", req.IPv6.String())
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	requestUrl := path + separator + params.Encode()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readResponse(resp)
+}
+
+// MaxResponseSize limits the size of the body of an HTTP tracker response.
+// Even with thousands of peers in the dictionary form, real responses stay
+// far below it.
+const MaxResponseSize = 1 << 20
+
+// readResponse reads the body of an HTTP tracker response, giving up once
+// it exceeds MaxResponseSize. Trackers may explain a failure in a body sent
+// with any status, so only an empty body makes one an error.
+func readResponse(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxResponseSize {
+		limitErr := &bencode.LimitError{Offset: MaxResponseSize, Limit: "size", Max: MaxResponseSize}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, limitErr)
+	}
+	if resp.StatusCode != http.StatusOK && len(body) == 0 {
+		return nil, fmt.Errorf("%w: HTTP %s", ErrInvalidResponse, resp.Status)
+	}
+	return body, nil
+}
+
+// Announce sends req to the tracker at rawURL, over HTTP or UDP depending
+// on its scheme, and parses its response.
+func Announce(ctx context.Context, rawURL string, req Request) (Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		body, err := DiscoverPeers(ctx, rawURL, req)
+		if err != nil {
+			return Response{}, err
+		}
+		return NewResponse(body)
+	case "udp":
+		return DefaultUDPClient.Announce(ctx, rawURL, req)
+	default:
+		return Response{}, fmt.Errorf("tracker: unsupported scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,110 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"torrent-client/bencode"
+)
+
+func peers(addrs ...string) []netip.AddrPort {
+	var result []netip.AddrPort
+	for _, i := range addrs {
+		result = append(result, netip.MustParseAddrPort(i))
+	}
+	return result
+}
+
+func TestNewResponse(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected *Response
+		err      error
+	}{
+		{"compact peers",
+			[]byte("d8:intervali900e5:peers12:\x7f\x00\x00\x01\x1a\xe1\x0a\x00\x00\x02\x1a\xe2e"),
+			&Response{Interval: 900, Peers: peers("127.0.0.1:6881", "10.0.0.2:6882")},
+			nil,
+		},
+		{"all fields",
+			[]byte("d8:completei5e10:incompletei2e8:intervali900e12:min intervali60e5:peers0:" +
+				"10:tracker id3:abc15:warning message4:slowe"),
+			&Response{Interval: 900, MinInterval: 60, TrackerID: "abc", Warning: "slow", Complete: 5, Incomplete: 2},
+			nil,
+		},
+		{"dictionary peers",
+			[]byte("d8:intervali900e5:peersld2:ip9:127.0.0.17:peer id20:-TC0001-0123456789ab4:porti6881eed2:ip3:::14:porti80eeee"),
+			&Response{Interval: 900, Peers: peers("127.0.0.1:6881", "[::1]:80")},
+			nil,
+		},
+		{"dictionary peer with host name",
+			[]byte("d8:intervali900e5:peersld2:ip11:example.com4:porti1eeee"),
+			&Response{Interval: 900},
+			nil,
+		},
+		{"IPv6 peers",
+			[]byte("d8:intervali900e5:peers6:\x7f\x00\x00\x01\x1a\xe16:peers618:" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe2e"),
+			&Response{Interval: 900, Peers: peers("127.0.0.1:6881", "[2001:db8::1]:6882")},
+			nil,
+		},
+		{"only IPv6 peers",
+			[]byte("d8:intervali900e6:peers60:e"),
+			&Response{Interval: 900},
+			nil,
+		},
+		{"truncated IPv6 peers", []byte("d8:intervali900e6:peers61:\x20e"), nil, ErrInvalidResponse},
+		{"dictionary peer without port", []byte("d8:intervali900e5:peersld2:ip9:127.0.0.1eee"), nil, ErrInvalidResponse},
+		{"truncated compact peers", []byte("d8:intervali900e5:peers5:\x7f\x00\x00\x01\x1ae"), nil, ErrInvalidResponse},
+		{"peers of wrong type", []byte("d8:intervali900e5:peersi1ee"), nil, ErrInvalidResponse},
+		{"failure reason", []byte("d14:failure reason9:not founde"), nil, &FailureError{}},
+		{"missing interval", []byte("d5:peers0:e"), nil, ErrInvalidResponse},
+		{"not a dictionary", []byte("li1ee"), nil, ErrInvalidResponse},
+		{"malformed", []byte("d8:interval"), nil, bencode.ErrMalformed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := NewResponse(c.input)
+
+			if c.expected == nil {
+				var failure *FailureError
+				if errors.As(c.err, &failure) {
+					if !errors.As(err, &failure) {
+						t.Error(err)
+					}
+				} else if !errors.Is(err, c.err) {
+					t.Error(err)
+				}
+			} else if err != nil || !reflect.DeepEqual(*c.expected, result) {
+				t.Error(result, err)
+			}
+		})
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	// A string as long as it claims, but longer than any tracker needs.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("d5:peers2000000:"))
+		w.Write(bytes.Repeat([]byte{1}, 2000000))
+		w.Write([]byte("e"))
+	}))
+	defer server.Close()
+
+	_, err := Announce(context.Background(), server.URL+"/announce", Request{})
+	if !errors.Is(err, ErrInvalidResponse) || !errors.Is(err, bencode.ErrLimitExceeded) {
+		t.Error(err)
+	}
+	_, err = Scrape(context.Background(), server.URL+"/announce", [20]byte{1})
+	if !errors.Is(err, ErrInvalidResponse) || !errors.Is(err, bencode.ErrLimitExceeded) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,241 @@
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Constants of the UDP tracker protocol (BEP 15).
+const (
+	udpProtocolID uint64 = 0x41727101980
+
+	actionConnect  uint32 = 0
+	actionAnnounce uint32 = 1
+	actionScrape   uint32 = 2
+	actionError    uint32 = 3
+
+	// connectionIDLifetime is how long a connection ID may be reused.
+	connectionIDLifetime = time.Minute
+)
+
+// errUDPTimeout is returned when a tracker never answers.
+var errUDPTimeout = errors.New("tracker: UDP tracker did not respond")
+
+// UDPClient talks to trackers using the UDP tracker protocol (BEP 15). It
+// caches connection IDs per tracker and retransmits unanswered requests
+// after Timeout * 2^n, for n up to MaxRetries.
+type UDPClient struct {
+	// Timeout is the time to wait for the first reply; BEP 15 uses 15s.
+	Timeout time.Duration
+	// MaxRetries is the number of retransmissions; BEP 15 uses 8.
+	MaxRetries int
+
+	mu  sync.Mutex
+	ids map[string]connectionID
+}
+
+type connectionID struct {
+	id      uint64
+	expires time.Time
+}
+
+// DefaultUDPClient is the UDPClient used by Announce.
+var DefaultUDPClient = NewUDPClient()
+
+// NewUDPClient returns a UDPClient using the timeouts of BEP 15.
+func NewUDPClient() *UDPClient {
+	return &UDPClient{
+		Timeout:    15 * time.Second,
+		MaxRetries: 8,
+		ids:        make(map[string]connectionID),
+	}
+}
+
+// Announce sends req to the UDP tracker at rawURL.
+func (c *UDPClient) Announce(ctx context.Context, rawURL string, req Request) (Response, error) {
+	numWant := int32(-1) // tracker default
+	if req.NumWant > 0 {
+		numWant = int32(req.NumWant)
+	}
+
+	payload := make([]byte, 82)
+	copy(payload[0:20], req.InfoHash[:])
+	copy(payload[20:40], req.PeerID[:])
+	binary.BigEndian.PutUint64(payload[40:48], uint64(req.Downloaded))
+	binary.BigEndian.PutUint64(payload[48:56], uint64(req.Left))
+	binary.BigEndian.PutUint64(payload[56:64], uint64(req.Uploaded))
+	binary.BigEndian.PutUint32(payload[64:68], uint32(req.Event))
+	binary.BigEndian.PutUint32(payload[68:72], 0) // IP address: the sender's
+	binary.BigEndian.PutUint32(payload[72:76], req.Key)
+	binary.BigEndian.PutUint32(payload[76:80], uint32(numWant))
+	binary.BigEndian.PutUint16(payload[80:82], req.Port)
+
+	body, remote, err := c.do(ctx, rawURL, actionAnnounce, payload)
+	if err != nil {
+		return Response{}, err
+	}
+	if len(body) < 12 {
+		return Response{}, fmt.Errorf("%w: announce reply of %d bytes", ErrInvalidResponse, len(body))
+	}
+
+	// Trackers reached over IPv6 answer with IPv6 peers.
+	peers := parseCompactPeers(body[12:])
+	if remote.Unmap().Is6() {
+		peers = parseCompactPeers6(body[12:])
+	}
+	return Response{
+		Interval:   int(binary.BigEndian.Uint32(body[0:4])),
+		Incomplete: int(binary.BigEndian.Uint32(body[4:8])),
+		Complete:   int(binary.BigEndian.Uint32(body[8:12])),
+		Peers:      peers,
+	}, nil
+}
+
+// Scrape asks the UDP tracker at rawURL for statistics about the torrents
+// identified by hashes, returned in the same order.
+func (c *UDPClient) Scrape(ctx context.Context, rawURL string, hashes ...[20]byte) ([]ScrapeStats, error) {
+	payload := make([]byte, 0, 20*len(hashes))
+	for _, hash := range hashes {
+		payload = append(payload, hash[:]...)
+	}
+
+	body, _, err := c.do(ctx, rawURL, actionScrape, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 12*len(hashes) {
+		return nil, fmt.Errorf("%w: scrape reply of %d bytes for %d hashes", ErrInvalidResponse, len(body), len(hashes))
+	}
+
+	result := make([]ScrapeStats, len(hashes))
+	for i := range result {
+		entry := body[12*i:]
+		result[i] = ScrapeStats{
+			Seeders:   int(binary.BigEndian.Uint32(entry[0:4])),
+			Completed: int(binary.BigEndian.Uint32(entry[4:8])),
+			Leechers:  int(binary.BigEndian.Uint32(entry[8:12])),
+		}
+	}
+	return result, nil
+}
+
+// do performs action with the tracker at rawURL, connecting first if no
+// valid connection ID is cached, and returns the reply after its header
+// along with the address it came from.
+func (c *UDPClient) do(ctx context.Context, rawURL string, action uint32, payload []byte) ([]byte, netip.Addr, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+	host := u.Host
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", host)
+	if err != nil {
+		return nil, netip.Addr{}, err
+	}
+	defer conn.Close()
+	remote := conn.RemoteAddr().(*net.UDPAddr).AddrPort().Addr()
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	for n := 0; n <= c.MaxRetries; n++ {
+		id, ok := c.connectionID(host)
+		if !ok {
+			packet := make([]byte, 16)
+			binary.BigEndian.PutUint64(packet[0:8], udpProtocolID)
+			reply, err := c.exchange(ctx, conn, packet, actionConnect, n)
+			if errors.Is(err, errUDPTimeout) {
+				continue
+			} else if err != nil {
+				return nil, remote, err
+			}
+			if len(reply) < 8 {
+				return nil, remote, fmt.Errorf("%w: connect reply of %d bytes", ErrInvalidResponse, len(reply))
+			}
+			id = binary.BigEndian.Uint64(reply[0:8])
+			c.setConnectionID(host, id)
+		}
+
+		packet := make([]byte, 16+len(payload))
+		binary.BigEndian.PutUint64(packet[0:8], id)
+		copy(packet[16:], payload)
+		reply, err := c.exchange(ctx, conn, packet, action, n)
+		if errors.Is(err, errUDPTimeout) {
+			continue
+		}
+		return reply, remote, err
+	}
+
+	return nil, remote, errUDPTimeout
+}
+
+// exchange sends packet, after filling in its action and a new transaction
+// ID, and waits up to Timeout * 2^n for the matching reply.
+func (c *UDPClient) exchange(ctx context.Context, conn net.Conn, packet []byte, action uint32, n int) ([]byte, error) {
+	transaction := rand.Uint32()
+	binary.BigEndian.PutUint32(packet[8:12], action)
+	binary.BigEndian.PutUint32(packet[12:16], transaction)
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(c.Timeout << n))
+	buf := make([]byte, 64*1024)
+	for {
+		m, err := conn.Read(buf)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, errUDPTimeout
+		} else if err != nil {
+			return nil, err
+		}
+
+		reply := buf[:m]
+		if m < 8 || binary.BigEndian.Uint32(reply[4:8]) != transaction {
+			// Not an answer to this request.
+			continue
+		}
+
+		switch binary.BigEndian.Uint32(reply[0:4]) {
+		case action:
+			return reply[8:], nil
+		case actionError:
+			return nil, &FailureError{Reason: string(reply[8:])}
+		default:
+			return nil, fmt.Errorf("%w: unexpected action %d", ErrInvalidResponse, binary.BigEndian.Uint32(reply[0:4]))
+		}
+	}
+}
+
+func (c *UDPClient) connectionID(host string) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.ids[host]
+	if !ok || time.Now().After(cached.expires) {
+		return 0, false
+	}
+	return cached.id, true
+}
+
+func (c *UDPClient) setConnectionID(host string, id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids == nil {
+		c.ids = make(map[string]connectionID)
+	}
+	c.ids[host] = connectionID{id: id, expires: time.Now().Add(connectionIDLifetime)}
+}
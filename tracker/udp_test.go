@@ -0,0 +1,171 @@
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// udpTracker is an in-process stand-in for a UDP tracker.
+type udpTracker struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	connects int
+	drop     int // number of requests to ignore before answering
+	failure  string
+}
+
+func newUDPTracker(t *testing.T, drop int) *udpTracker {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &udpTracker{conn: conn, drop: drop}
+	go tracker.serve()
+	t.Cleanup(func() { conn.Close() })
+	return tracker
+}
+
+func (s *udpTracker) url() string {
+	return "udp://" + s.conn.LocalAddr().String() + "/announce"
+}
+
+func (s *udpTracker) serve() {
+	const connectionID = 0xC0FFEE
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		packet := buf[:n]
+		action := binary.BigEndian.Uint32(packet[8:12])
+		transaction := packet[12:16]
+
+		s.mu.Lock()
+		if s.drop > 0 {
+			s.drop--
+			s.mu.Unlock()
+			continue
+		}
+		failure := s.failure
+		if action == actionConnect {
+			s.connects++
+		}
+		s.mu.Unlock()
+
+		reply := binary.BigEndian.AppendUint32(nil, action)
+		reply = append(reply, transaction...)
+		switch {
+		case action == actionConnect:
+			if binary.BigEndian.Uint64(packet[0:8]) != udpProtocolID {
+				continue
+			}
+			reply = binary.BigEndian.AppendUint64(reply, connectionID)
+		case binary.BigEndian.Uint64(packet[0:8]) != connectionID:
+			continue
+		case failure != "":
+			binary.BigEndian.PutUint32(reply[0:4], actionError)
+			reply = append(reply, failure...)
+		case action == actionAnnounce:
+			reply = binary.BigEndian.AppendUint32(reply, 1800) // interval
+			reply = binary.BigEndian.AppendUint32(reply, 2)    // leechers
+			reply = binary.BigEndian.AppendUint32(reply, 5)    // seeders
+			reply = append(reply, 10, 0, 0, 1, 0x1a, 0xe1)
+		case action == actionScrape:
+			for i := 16; i+20 <= len(packet); i += 20 {
+				reply = binary.BigEndian.AppendUint32(reply, uint32(packet[i]))
+				reply = binary.BigEndian.AppendUint32(reply, 7)
+				reply = binary.BigEndian.AppendUint32(reply, 3)
+			}
+		}
+		s.conn.WriteToUDP(reply, addr)
+	}
+}
+
+func newTestUDPClient() *UDPClient {
+	c := NewUDPClient()
+	c.Timeout = 20 * time.Millisecond
+	c.MaxRetries = 3
+	return c
+}
+
+func TestUDPAnnounce(t *testing.T) {
+	server := newUDPTracker(t, 0)
+	c := newTestUDPClient()
+
+	for range 2 {
+		resp, err := c.Announce(context.Background(), server.url(), Request{Left: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Response{Interval: 1800, Complete: 5, Incomplete: 2, Peers: []netip.AddrPort{netip.MustParseAddrPort("10.0.0.1:6881")}}
+		if !reflect.DeepEqual(expected, resp) {
+			t.Error(resp)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.connects != 1 {
+		t.Error("connection ID should be reused, connected", server.connects, "times")
+	}
+}
+
+func TestUDPRetransmit(t *testing.T) {
+	server := newUDPTracker(t, 2)
+	c := newTestUDPClient()
+
+	if _, err := c.Announce(context.Background(), server.url(), Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	server.drop = 100
+	server.mu.Unlock()
+	c.ids = nil
+	if _, err := c.Announce(context.Background(), server.url(), Request{}); !errors.Is(err, errUDPTimeout) {
+		t.Error(err)
+	}
+}
+
+func TestUDPScrapeAndFailure(t *testing.T) {
+	server := newUDPTracker(t, 0)
+	c := newTestUDPClient()
+
+	stats, err := c.Scrape(context.Background(), server.url(), [20]byte{1}, [20]byte{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScrapeStats{{Seeders: 1, Completed: 7, Leechers: 3}, {Seeders: 2, Completed: 7, Leechers: 3}}
+	if !reflect.DeepEqual(expected, stats) {
+		t.Error(stats)
+	}
+
+	server.mu.Lock()
+	server.failure = "torrent not registered"
+	server.mu.Unlock()
+	var failure *FailureError
+	_, err = c.Announce(context.Background(), server.url(), Request{})
+	if !errors.As(err, &failure) || failure.Reason != "torrent not registered" {
+		t.Error(err)
+	}
+}
+
+func TestUDPContext(t *testing.T) {
+	server := newUDPTracker(t, 100)
+	c := NewUDPClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Announce(ctx, server.url(), Request{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+}